@@ -0,0 +1,60 @@
+// Команда tasksadmin выполняет операции обслуживания БД задач.
+//
+//	tasksadmin -db <строка подключения> pool|hints|cleanup|schema [-dry-run]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"30-5/pkg/storage"
+)
+
+func main() {
+	constr := flag.String("db", os.Getenv("TASKS_DB"), "строка подключения к БД")
+	dryRun := flag.Bool("dry-run", false, "cleanup: только подсчитать удаляемые строки")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: tasksadmin [flags] pool|hints|cleanup|schema")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	s, err := storage.New(*constr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	var result any
+	switch flag.Arg(0) {
+	case "pool":
+		result = s.PoolStats()
+	case "hints":
+		result, err = s.MaintenanceHints(ctx)
+	case "cleanup":
+		if *dryRun {
+			ctx = storage.WithDryRun(ctx)
+		}
+		result, err = s.CleanupOrphans(ctx)
+	case "schema":
+		result, err = s.SchemaStatus(ctx)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(result)
+}
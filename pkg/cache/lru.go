@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+
+	"30-5/pkg/storage"
+)
+
+// LRU кэширует в памяти процесса задачи по id и метки задач,
+// вытесняя давно не использованные записи сверх size.
+// Записи задачи сбрасываются при её изменении через декоратор,
+// а изменения других процессов учитываются через Watch.
+// Предназначен для развёртываний с одним экземпляром приложения
+// или вместе с Watch.
+type LRU struct {
+	storage.Interface
+
+	mu    sync.Mutex
+	size  int
+	ll    *list.List // от недавно использованных к давним
+	items map[lruKey]*list.Element
+	// epoch увеличивается при каждом сбросе; значение, загруженное
+	// до сброса, в кэш не сохраняется
+	epoch uint64
+	stats Stats
+}
+
+// Ключ записи: вид значения и id задачи.
+type lruKey struct {
+	kind byte // 't' - задача, 'l' - метки задачи
+	id   int
+}
+
+type lruEntry struct {
+	key   lruKey
+	value any
+}
+
+// NewLRU оборачивает хранилище s кэшем в памяти на size записей.
+func NewLRU(s storage.Interface, size int) *LRU {
+	return &LRU{
+		Interface: s,
+		size:      size,
+		ll:        list.New(),
+		items:     make(map[lruKey]*list.Element, size),
+	}
+}
+
+// TaskByID возвращает задачу из кэша или из хранилища.
+func (c *LRU) TaskByID(ctx context.Context, id int) (storage.Task, error) {
+	key := lruKey{'t', id}
+	v, epoch, ok := c.get(key)
+	if ok {
+		return v.(storage.Task), nil
+	}
+	t, err := c.Interface.TaskByID(ctx, id)
+	if err != nil {
+		return storage.Task{}, err
+	}
+	c.add(key, t, epoch)
+	return t, nil
+}
+
+// TaskLabels возвращает метки задачи из кэша или из хранилища.
+// Возвращаемый срез нельзя изменять: он разделяется между вызовами.
+func (c *LRU) TaskLabels(ctx context.Context, taskID int) ([]storage.Label, error) {
+	key := lruKey{'l', taskID}
+	v, epoch, ok := c.get(key)
+	if ok {
+		return v.([]storage.Label), nil
+	}
+	labels, err := c.Interface.TaskLabels(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	c.add(key, labels, epoch)
+	return labels, nil
+}
+
+// Peek возвращает задачу из кэша без обращения к хранилищу.
+func (c *LRU) Peek(id int) (storage.Task, bool) {
+	v, _, ok := c.get(lruKey{'t', id})
+	if !ok {
+		return storage.Task{}, false
+	}
+	return v.(storage.Task), true
+}
+
+// UpdateTask обновляет задачу и удаляет её из кэша.
+func (c *LRU) UpdateTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	defer c.Invalidate(t.ID)
+	return c.Interface.UpdateTask(ctx, t)
+}
+
+// DeleteTask удаляет задачу из хранилища и из кэша.
+func (c *LRU) DeleteTask(ctx context.Context, id int) (storage.DeleteStats, error) {
+	defer c.Invalidate(id)
+	return c.Interface.DeleteTask(ctx, id)
+}
+
+// CloseTasks закрывает задачи и удаляет их из кэша.
+func (c *LRU) CloseTasks(ctx context.Context, ids []int) (storage.BatchResult, error) {
+	defer c.Invalidate(ids...)
+	return c.Interface.CloseTasks(ctx, ids)
+}
+
+// ReassignTasks меняет ответственного и удаляет задачи из кэша.
+func (c *LRU) ReassignTasks(ctx context.Context, ids []int, newAssignee int) (storage.BatchResult, error) {
+	defer c.Invalidate(ids...)
+	return c.Interface.ReassignTasks(ctx, ids, newAssignee)
+}
+
+// AddLabelToTasks добавляет метку задачам и удаляет их из кэша.
+func (c *LRU) AddLabelToTasks(ctx context.Context, labelName string, taskIDs []int) (storage.BatchResult, error) {
+	defer c.Invalidate(taskIDs...)
+	return c.Interface.AddLabelToTasks(ctx, labelName, taskIDs)
+}
+
+// RemoveLabelFromTasks снимает метку с задач и удаляет их из кэша.
+func (c *LRU) RemoveLabelFromTasks(ctx context.Context, labelName string, taskIDs []int) (storage.BatchResult, error) {
+	defer c.Invalidate(taskIDs...)
+	return c.Interface.RemoveLabelFromTasks(ctx, labelName, taskIDs)
+}
+
+// Invalidate удаляет из кэша записи задач ids.
+func (c *LRU) Invalidate(ids ...int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	for _, id := range ids {
+		for _, kind := range []byte{'t', 'l'} {
+			if el, ok := c.items[lruKey{kind, id}]; ok {
+				c.ll.Remove(el)
+				delete(c.items, lruKey{kind, id})
+			}
+		}
+	}
+}
+
+// Purge очищает кэш.
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	c.ll.Init()
+	c.items = make(map[lruKey]*list.Element, c.size)
+}
+
+// Notifier - источник уведомлений об изменении задач,
+// например *storage.Storage.
+type Notifier interface {
+	Listen(ctx context.Context, channel string, fn func(payload string)) error
+}
+
+// Watch сбрасывает записи задач, изменённых другими процессами,
+// по уведомлениям канала storage.NotifyChannel до отмены контекста
+// или ошибки соединения. При подписке и при её потере кэш очищается
+// целиком, так как уведомления за это время недоступны.
+func (c *LRU) Watch(ctx context.Context, n Notifier) error {
+	defer c.Purge()
+	return n.Listen(ctx, storage.NotifyChannel, func(payload string) {
+		id, err := strconv.Atoi(payload)
+		if err != nil {
+			c.Purge()
+			return
+		}
+		c.Invalidate(id)
+	})
+}
+
+// Stats возвращает счётчики обращений к кэшу.
+func (c *LRU) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// get возвращает значение по ключу и текущую эпоху кэша.
+func (c *LRU) get(key lruKey) (any, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, c.epoch, false
+	}
+	c.stats.Hits++
+	c.ll.MoveToFront(el)
+	return el.Value.(*lruEntry).value, c.epoch, true
+}
+
+// add сохраняет значение, загруженное в эпоху epoch,
+// если с тех пор кэш не сбрасывался.
+func (c *LRU) add(key lruKey, value any, epoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if epoch != c.epoch || c.size <= 0 {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry).value = value
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key, value})
+	if c.ll.Len() > c.size {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.items, last.Value.(*lruEntry).key)
+	}
+}
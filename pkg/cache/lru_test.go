@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"30-5/pkg/storage"
+)
+
+// countingStorage отдаёт задачи с заголовком title и считает обращения.
+type countingStorage struct {
+	storage.Interface
+	title string
+	calls map[int]int
+	// during, если задан, вызывается во время загрузки задачи
+	during func()
+}
+
+func (s *countingStorage) TaskByID(ctx context.Context, id int) (storage.Task, error) {
+	s.calls[id]++
+	if s.during != nil {
+		s.during()
+	}
+	return storage.Task{ID: id, Title: s.title}, nil
+}
+
+func TestLRUEviction(t *testing.T) {
+	// последовательность обращений и ожидаемые обращения к хранилищу
+	tests := []struct {
+		name  string
+		size  int
+		gets  []int
+		calls map[int]int
+	}{
+		{"hits", 2, []int{1, 1, 1}, map[int]int{1: 1}},
+		{"within size", 2, []int{1, 2, 1, 2}, map[int]int{1: 1, 2: 1}},
+		{"evicts least recent", 2, []int{1, 2, 3, 1}, map[int]int{1: 2, 2: 1, 3: 1}},
+		{"use refreshes recency", 2, []int{1, 2, 1, 3, 1, 2}, map[int]int{1: 1, 2: 2, 3: 1}},
+		{"zero size disables", 0, []int{1, 1}, map[int]int{1: 2}},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &countingStorage{calls: make(map[int]int)}
+			c := NewLRU(st, tt.size)
+			for _, id := range tt.gets {
+				got, err := c.TaskByID(ctx, id)
+				if err != nil || got.ID != id {
+					t.Fatalf("TaskByID(%d) = %+v, %v", id, got, err)
+				}
+			}
+			for id, want := range tt.calls {
+				if st.calls[id] != want {
+					t.Errorf("storage calls for %d = %d, want %d", id, st.calls[id], want)
+				}
+			}
+			if c.ll.Len() > tt.size || len(c.items) != c.ll.Len() {
+				t.Errorf("list %d, map %d entries, size %d", c.ll.Len(), len(c.items), tt.size)
+			}
+		})
+	}
+}
+
+func TestLRUInvalidate(t *testing.T) {
+	ctx := context.Background()
+	st := &countingStorage{title: "old", calls: make(map[int]int)}
+	c := NewLRU(st, 10)
+	c.TaskByID(ctx, 1)
+	c.TaskByID(ctx, 2)
+	st.title = "new"
+	c.Invalidate(1)
+	if got, _ := c.TaskByID(ctx, 1); got.Title != "new" {
+		t.Errorf("invalidated task title = %q, want new", got.Title)
+	}
+	if got, _ := c.TaskByID(ctx, 2); got.Title != "old" {
+		t.Errorf("other task title = %q, want cached old", got.Title)
+	}
+	c.Purge()
+	if got, _ := c.TaskByID(ctx, 2); got.Title != "new" {
+		t.Errorf("title after purge = %q, want new", got.Title)
+	}
+}
+
+func TestLRUEpoch(t *testing.T) {
+	// сброс во время загрузки: загруженное значение может быть
+	// устаревшим и не должно попасть в кэш
+	tests := []struct {
+		name  string
+		reset func(c *LRU)
+	}{
+		{"invalidate", func(c *LRU) { c.Invalidate(1) }},
+		{"invalidate other task", func(c *LRU) { c.Invalidate(2) }},
+		{"purge", func(c *LRU) { c.Purge() }},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &countingStorage{calls: make(map[int]int)}
+			c := NewLRU(st, 10)
+			st.during = func() { tt.reset(c) }
+			c.TaskByID(ctx, 1)
+			st.during = nil
+			if _, ok := c.Peek(1); ok {
+				t.Fatal("value loaded before reset was cached")
+			}
+			c.TaskByID(ctx, 1)
+			if _, ok := c.Peek(1); !ok {
+				t.Fatal("value loaded after reset was not cached")
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+// Пакет cache содержит кэширующие декораторы хранилища задач.
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"30-5/pkg/storage"
+)
+
+// RedisClient - минимальный набор команд Redis, нужный кэшу.
+// Реализуется адаптером над используемой клиентской библиотекой.
+type RedisClient interface {
+	// Get возвращает значение ключа; ok == false, если ключа нет.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// Redis кэширует в Redis задачи по id и часто запрашиваемые списки.
+// Кэш сбрасывается целиком: при любом изменении через декоратор
+// увеличивается номер поколения, входящий в ключи задач и списков,
+// а старые записи истекают по TTL. Поколение читается до загрузки
+// из хранилища, так что значение, загруженное до изменения, попадает
+// под старый ключ и не читается.
+// Изменения в обход декоратора (Snooze, PublishTask, MergeTasks,
+// CloneTask, импорт) и изменения других процессов учитываются
+// через Watch, без него - по истечении TTL.
+type Redis struct {
+	storage.Interface
+
+	client  RedisClient
+	prefix  string
+	taskTTL time.Duration
+	listTTL time.Duration
+
+	// OnError, если задан, вызывается при ошибках Redis.
+	// Ошибки кэша не прерывают операции: чтение идёт в хранилище.
+	OnError func(error)
+
+	hits, misses atomic.Uint64
+}
+
+// NewRedis оборачивает хранилище s кэшем в Redis.
+// Задачи хранятся taskTTL, списки - listTTL.
+func NewRedis(s storage.Interface, client RedisClient, taskTTL, listTTL time.Duration) *Redis {
+	return &Redis{
+		Interface: s,
+		client:    client,
+		prefix:    "tasks:",
+		taskTTL:   taskTTL,
+		listTTL:   listTTL,
+	}
+}
+
+// TaskByID возвращает задачу из кэша или из хранилища.
+func (c *Redis) TaskByID(ctx context.Context, id int) (storage.Task, error) {
+	gen, ok := c.generation(ctx)
+	if !ok {
+		return c.Interface.TaskByID(ctx, id)
+	}
+	var t storage.Task
+	key := c.prefix + "task:" + gen + ":" + strconv.Itoa(id)
+	if c.get(ctx, key, &t) {
+		return t, nil
+	}
+	t, err := c.Interface.TaskByID(ctx, id)
+	if err != nil {
+		return storage.Task{}, err
+	}
+	c.set(ctx, key, t, c.taskTTL)
+	return t, nil
+}
+
+// Tasks возвращает список задач из кэша или из хранилища.
+func (c *Redis) Tasks(ctx context.Context, taskID, authorID int) ([]storage.Task, error) {
+	return c.list(ctx, "all:"+strconv.Itoa(taskID)+":"+strconv.Itoa(authorID), func() ([]storage.Task, error) {
+		return c.Interface.Tasks(ctx, taskID, authorID)
+	})
+}
+
+// TaskByAuthor возвращает задачи автора из кэша или из хранилища.
+func (c *Redis) TaskByAuthor(ctx context.Context, authorID int) ([]storage.Task, error) {
+	return c.list(ctx, "author:"+strconv.Itoa(authorID), func() ([]storage.Task, error) {
+		return c.Interface.TaskByAuthor(ctx, authorID)
+	})
+}
+
+// TaskByLabel возвращает задачи с меткой из кэша или из хранилища.
+func (c *Redis) TaskByLabel(ctx context.Context, labelName string) ([]storage.Task, error) {
+	return c.list(ctx, "label:"+hash(labelName), func() ([]storage.Task, error) {
+		return c.Interface.TaskByLabel(ctx, labelName)
+	})
+}
+
+// TasksByFilter возвращает задачи по фильтру из кэша или из хранилища.
+func (c *Redis) TasksByFilter(ctx context.Context, f storage.TaskFilter) ([]storage.Task, error) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return c.list(ctx, "filter:"+hash(string(b)), func() ([]storage.Task, error) {
+		return c.Interface.TasksByFilter(ctx, f)
+	})
+}
+
+// NewTask создаёт задачу и сбрасывает кэш.
+func (c *Redis) NewTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	t, err := c.Interface.NewTask(ctx, t)
+	c.invalidate(ctx)
+	return t, err
+}
+
+// NewTaskWithLabels создаёт задачу с метками и сбрасывает кэш.
+func (c *Redis) NewTaskWithLabels(ctx context.Context, t storage.Task, labels []string) (storage.Task, error) {
+	t, err := c.Interface.NewTaskWithLabels(ctx, t, labels)
+	c.invalidate(ctx)
+	return t, err
+}
+
+// UpdateTask обновляет задачу и сбрасывает кэш.
+func (c *Redis) UpdateTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	updated, err := c.Interface.UpdateTask(ctx, t)
+	c.invalidate(ctx)
+	return updated, err
+}
+
+// DeleteTask удаляет задачу из хранилища и сбрасывает кэш.
+func (c *Redis) DeleteTask(ctx context.Context, id int) (storage.DeleteStats, error) {
+	stats, err := c.Interface.DeleteTask(ctx, id)
+	c.invalidate(ctx)
+	return stats, err
+}
+
+// CloseTasks закрывает задачи и сбрасывает кэш.
+func (c *Redis) CloseTasks(ctx context.Context, ids []int) (storage.BatchResult, error) {
+	res, err := c.Interface.CloseTasks(ctx, ids)
+	c.invalidate(ctx)
+	return res, err
+}
+
+// ReassignTasks меняет ответственного и сбрасывает кэш.
+func (c *Redis) ReassignTasks(ctx context.Context, ids []int, newAssignee int) (storage.BatchResult, error) {
+	res, err := c.Interface.ReassignTasks(ctx, ids, newAssignee)
+	c.invalidate(ctx)
+	return res, err
+}
+
+// AddLabelToTasks добавляет метку задачам и сбрасывает кэш.
+func (c *Redis) AddLabelToTasks(ctx context.Context, labelName string, taskIDs []int) (storage.BatchResult, error) {
+	res, err := c.Interface.AddLabelToTasks(ctx, labelName, taskIDs)
+	c.invalidate(ctx)
+	return res, err
+}
+
+// RemoveLabelFromTasks снимает метку с задач и сбрасывает кэш.
+func (c *Redis) RemoveLabelFromTasks(ctx context.Context, labelName string, taskIDs []int) (storage.BatchResult, error) {
+	res, err := c.Interface.RemoveLabelFromTasks(ctx, labelName, taskIDs)
+	c.invalidate(ctx)
+	return res, err
+}
+
+// Reset сбрасывает кэш, например после импорта задач
+// в обход декоратора.
+func (c *Redis) Reset(ctx context.Context) {
+	c.invalidate(ctx)
+}
+
+// Watch сбрасывает кэш по уведомлениям канала storage.NotifyChannel
+// об изменении задач и их меток, в том числе выполненном в обход
+// декоратора или другими процессами, до отмены контекста или ошибки
+// соединения. Кэш сбрасывается и при подписке, и при её потере,
+// так как уведомления за это время недоступны.
+func (c *Redis) Watch(ctx context.Context, n Notifier) error {
+	c.invalidate(ctx)
+	defer c.invalidate(context.Background())
+	return n.Listen(ctx, storage.NotifyChannel, func(string) {
+		c.invalidate(ctx)
+	})
+}
+
+// list возвращает список задач по ключу name текущего поколения,
+// при промахе загружая его функцией load.
+func (c *Redis) list(ctx context.Context, name string, load func() ([]storage.Task, error)) ([]storage.Task, error) {
+	gen, ok := c.generation(ctx)
+	if !ok {
+		return load()
+	}
+	key := c.prefix + "list:" + gen + ":" + name
+	var tasks []storage.Task
+	if c.get(ctx, key, &tasks) {
+		return tasks, nil
+	}
+	tasks, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.set(ctx, key, tasks, c.listTTL)
+	return tasks, nil
+}
+
+// generation возвращает текущее поколение списков.
+// ok == false, если Redis недоступен.
+func (c *Redis) generation(ctx context.Context) (string, bool) {
+	b, found, err := c.client.Get(ctx, c.prefix+"gen")
+	if err != nil {
+		c.fail(err)
+		return "", false
+	}
+	if !found {
+		return "0", true
+	}
+	return string(b), true
+}
+
+// invalidate сбрасывает кэш, начиная новое поколение.
+// Вызывается и при ошибке операции: изменения могли частично
+// примениться, а лишний сброс кэша безопасен.
+func (c *Redis) invalidate(ctx context.Context) {
+	if _, err := c.client.Incr(ctx, c.prefix+"gen"); err != nil {
+		c.fail(err)
+	}
+}
+
+// get читает и декодирует значение ключа в v; false при промахе или ошибке.
+func (c *Redis) get(ctx context.Context, key string, v any) bool {
+	b, ok, err := c.client.Get(ctx, key)
+	if err != nil {
+		c.fail(err)
+		return false
+	}
+	if !ok {
+		c.misses.Add(1)
+		return false
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		c.fail(err)
+		return false
+	}
+	c.hits.Add(1)
+	return true
+}
+
+// Stats возвращает счётчики обращений к кэшу; ошибки Redis
+// не учитываются.
+func (c *Redis) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// set кодирует v и сохраняет под ключом key на время ttl.
+func (c *Redis) set(ctx context.Context, key string, v any, ttl time.Duration) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	if err = c.client.Set(ctx, key, b, ttl); err != nil {
+		c.fail(err)
+	}
+}
+
+func (c *Redis) fail(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// hash сокращает произвольную строку до ключа фиксированной длины.
+func hash(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
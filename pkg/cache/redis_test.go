@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapRedis - RedisClient в памяти без учёта TTL.
+type mapRedis struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (r *mapRedis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.data[key]
+	return b, ok, nil
+}
+
+func (r *mapRedis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data[key] = value
+	return nil
+}
+
+func (r *mapRedis) Incr(ctx context.Context, key string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, _ := strconv.ParseInt(string(r.data[key]), 10, 64)
+	n++
+	r.data[key] = []byte(strconv.FormatInt(n, 10))
+	return n, nil
+}
+
+// notifierFunc - Notifier, вызывающий функцию с обработчиком
+// уведомлений и завершающий подписку после её возврата.
+type notifierFunc func(fn func(payload string))
+
+func (f notifierFunc) Listen(ctx context.Context, channel string, fn func(payload string)) error {
+	f(fn)
+	return nil
+}
+
+func TestRedisGeneration(t *testing.T) {
+	ctx := context.Background()
+	st := &countingStorage{title: "old", calls: make(map[int]int)}
+	c := NewRedis(st, &mapRedis{data: make(map[string][]byte)}, time.Minute, time.Minute)
+	// сброс во время загрузки: загруженное значение может быть
+	// устаревшим и не должно читаться из кэша
+	st.during = func() { c.Reset(ctx) }
+	if got, _ := c.TaskByID(ctx, 1); got.Title != "old" {
+		t.Fatalf("loaded title = %q, want old", got.Title)
+	}
+	st.during = nil
+	st.title = "new"
+	if got, _ := c.TaskByID(ctx, 1); got.Title != "new" {
+		t.Errorf("title after concurrent reset = %q, want new", got.Title)
+	}
+	if got, _ := c.TaskByID(ctx, 1); got.Title != "new" || st.calls[1] != 2 {
+		t.Errorf("cached title = %q after %d loads, want new after 2", got.Title, st.calls[1])
+	}
+}
+
+func TestRedisWatch(t *testing.T) {
+	ctx := context.Background()
+	st := &countingStorage{title: "old", calls: make(map[int]int)}
+	c := NewRedis(st, &mapRedis{data: make(map[string][]byte)}, time.Minute, time.Minute)
+	err := c.Watch(ctx, notifierFunc(func(notify func(string)) {
+		c.TaskByID(ctx, 1)
+		// изменение в обход декоратора, например Snooze
+		st.title = "new"
+		notify("1")
+		if got, _ := c.TaskByID(ctx, 1); got.Title != "new" {
+			t.Errorf("title after notification = %q, want new", got.Title)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,16 @@
+package cache
+
+// Счётчики обращений к кэшу с момента создания. LRU ведёт их под своей
+// блокировкой, а Redis, у которого блокировки нет, - атомарно.
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// HitRate возвращает долю попаданий в кэш, 0 - если обращений не было.
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+
+	"30-5/pkg/storage"
+)
+
+// WarmSource перечисляет ответственных и метки для прогрева кэша,
+// например *storage.Storage.
+type WarmSource interface {
+	Assignees(ctx context.Context) ([]int, error)
+	LabelStats(ctx context.Context) ([]storage.LabelStat, error)
+}
+
+// OpenByAssignee - фильтр списка открытых задач ответственного
+// в порядке разбора. Списки, запрашиваемые с этим фильтром,
+// попадают в кэш Redis, прогретый Warm.
+func OpenByAssignee(id int) storage.TaskFilter {
+	return storage.TaskFilter{AssignedID: id, Order: storage.OrderTriage}
+}
+
+// OpenByLabel - фильтр списка открытых задач с меткой в порядке разбора.
+func OpenByLabel(name string) storage.TaskFilter {
+	return storage.TaskFilter{Label: name, Order: storage.OrderTriage}
+}
+
+// Warm загружает в кэш Redis списки открытых задач каждого
+// ответственного и каждой метки с открытыми задачами, чтобы первые
+// запросы после запуска не шли в БД. Прогревается только Redis:
+// LRU кэширует задачи по id, а не списки.
+// Вызывается при старте и после массовых изменений в обход кэша,
+// например импорта; перед этим нужно вызвать Reset.
+// Возвращает число загруженных списков.
+func Warm(ctx context.Context, c *Redis, src WarmSource) (int, error) {
+	assignees, err := src.Assignees(ctx)
+	if err != nil {
+		return 0, err
+	}
+	stats, err := src.LabelStats(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, id := range assignees {
+		if _, err = c.TasksByFilter(ctx, OpenByAssignee(id)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	for _, st := range stats {
+		if st.Open == 0 {
+			continue
+		}
+		if _, err = c.TasksByFilter(ctx, OpenByLabel(st.Name)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
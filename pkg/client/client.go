@@ -0,0 +1,193 @@
+// Пакет client - клиент API задач и меток для сторонних сервисов.
+// Методы и типы повторяют описание OpenAPI, которое отдаёт
+// server.OpenAPI (pkg/server/openapi.json), и не зависят
+// от пакета storage и драйвера БД.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Задача.
+type Task struct {
+	ID         int    `json:"id"`
+	Opened     int64  `json:"opened"`
+	Closed     int64  `json:"closed"`
+	Updated    int64  `json:"updated"`
+	Due        int64  `json:"due"`
+	Priority   int    `json:"priority"`
+	Estimate   int    `json:"estimate"`
+	AuthorID   int    `json:"author_id"`
+	AssignedID int    `json:"assigned_id"`
+	EpicID     int    `json:"epic_id"`
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Draft      bool   `json:"draft"`
+	Snoozed    int64  `json:"snoozed"`
+	UID        string `json:"uid"`
+}
+
+// Результат поиска: задача и заголовок с выделенными совпадениями.
+type SearchHit struct {
+	Task
+	Highlight string `json:"highlight"`
+}
+
+// Правило назначения ответственных.
+type AssignmentRule struct {
+	ID        int    `json:"id,omitempty"`
+	Position  int    `json:"position"`
+	Label     string `json:"label"`
+	EpicID    int    `json:"epic_id"`
+	Assignees []int  `json:"assignees"`
+	Strategy  string `json:"strategy,omitempty"` // "round_robin" или "least_open"
+}
+
+// Фильтр списка задач; поля с нулевыми значениями выборку не ограничивают.
+type TaskFilter struct {
+	AuthorID   int
+	AssignedID int
+	Label      string
+	EpicID     int
+	Limit      int
+	Offset     int
+	AfterID    int
+}
+
+// Ошибка API.
+type Error struct {
+	Status  int    // статус HTTP
+	Code    string `json:"code"` // стабильный код ошибки, например "not_found"
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("tasks api: status %d", e.Status)
+	}
+	return fmt.Sprintf("tasks api: %s: %s", e.Code, e.Message)
+}
+
+// Client - клиент API по адресу BaseURL.
+type Client struct {
+	BaseURL string       // например "https://tasks.example.com"
+	APIKey  string       // ключ API, передаётся как Bearer
+	HTTP    *http.Client // nil - http.DefaultClient
+}
+
+// New создаёт клиент API по адресу baseURL с ключом apiKey.
+func New(baseURL, apiKey string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), APIKey: apiKey}
+}
+
+// ListTasks возвращает задачи, подходящие под фильтр (GET /tasks).
+func (c *Client) ListTasks(ctx context.Context, f TaskFilter) ([]Task, error) {
+	q := url.Values{}
+	setInt(q, "author", f.AuthorID)
+	setInt(q, "assigned", f.AssignedID)
+	setInt(q, "epic", f.EpicID)
+	setInt(q, "limit", f.Limit)
+	setInt(q, "offset", f.Offset)
+	setInt(q, "after", f.AfterID)
+	if f.Label != "" {
+		q.Set("label", f.Label)
+	}
+	var tasks []Task
+	err := c.do(ctx, http.MethodGet, "/tasks", q, nil, &tasks)
+	return tasks, err
+}
+
+// SearchTasks ищет задачи по подстроке заголовка (GET /tasks/search).
+// limit 0 - число результатов по умолчанию.
+func (c *Client) SearchTasks(ctx context.Context, query string, limit int) ([]SearchHit, error) {
+	q := url.Values{"q": {query}}
+	setInt(q, "limit", limit)
+	var hits []SearchHit
+	err := c.do(ctx, http.MethodGet, "/tasks/search", q, nil, &hits)
+	return hits, err
+}
+
+// AssignmentRules возвращает правила назначения в порядке проверки
+// (GET /assignment-rules).
+func (c *Client) AssignmentRules(ctx context.Context) ([]AssignmentRule, error) {
+	var rules []AssignmentRule
+	err := c.do(ctx, http.MethodGet, "/assignment-rules", nil, nil, &rules)
+	return rules, err
+}
+
+// NewAssignmentRule создаёт правило назначения и возвращает его id
+// (POST /assignment-rules).
+func (c *Client) NewAssignmentRule(ctx context.Context, r AssignmentRule) (int, error) {
+	var resp struct {
+		ID int `json:"id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/assignment-rules", nil, r, &resp)
+	return resp.ID, err
+}
+
+// DeleteAssignmentRule удаляет правило назначения
+// (DELETE /assignment-rules/{id}).
+func (c *Client) DeleteAssignmentRule(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/assignment-rules/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+// do выполняет запрос с телом in в JSON и декодирует ответ в out.
+// Ответ с ошибкой возвращается как *Error.
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, in, out any) error {
+	u := c.BaseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	hc := c.HTTP
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		e := &Error{Status: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(e)
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// setInt задаёт параметр name, если n не нулевое.
+func setInt(q url.Values, name string, n int) {
+	if n != 0 {
+		q.Set(name, strconv.Itoa(n))
+	}
+}
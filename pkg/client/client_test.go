@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"30-5/pkg/server"
+	"30-5/pkg/storage"
+)
+
+// fakeStore отдаёт задачи в памяти через обработчики пакета server.
+type fakeStore struct {
+	tasks []storage.Task
+	last  storage.TaskFilter
+}
+
+func (s *fakeStore) TasksByFilter(ctx context.Context, f storage.TaskFilter) ([]storage.Task, error) {
+	s.last = f
+	return s.tasks, nil
+}
+
+func (s *fakeStore) TasksFingerprint(ctx context.Context, f storage.TaskFilter) (string, error) {
+	return "fp", nil
+}
+
+func (s *fakeStore) TasksByTitle(ctx context.Context, pattern string, limit int) ([]storage.Task, error) {
+	return s.tasks[:limit], nil
+}
+
+func newTestClient(t *testing.T, st *fakeStore) *Client {
+	mux := http.NewServeMux()
+	mux.Handle("/tasks", server.Tasks(st))
+	mux.Handle("/tasks/search", server.Search(st))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return New(srv.URL, "")
+}
+
+func TestListTasks(t *testing.T) {
+	st := &fakeStore{tasks: []storage.Task{{ID: 1, Title: "first", AuthorID: 7}}}
+	c := newTestClient(t, st)
+	tasks, err := c.ListTasks(context.Background(), TaskFilter{AuthorID: 7, Label: "bug", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != 1 || tasks[0].Title != "first" || tasks[0].AuthorID != 7 {
+		t.Fatalf("tasks = %+v", tasks)
+	}
+	want := storage.TaskFilter{AuthorID: 7, Label: "bug", Limit: 10}
+	if st.last != want {
+		t.Fatalf("filter = %+v, want %+v", st.last, want)
+	}
+}
+
+func TestSearchTasks(t *testing.T) {
+	st := &fakeStore{tasks: []storage.Task{{ID: 1, Title: "Fix bug"}, {ID: 2, Title: "bug report"}}}
+	c := newTestClient(t, st)
+	hits, err := c.SearchTasks(context.Background(), "bug", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].ID != 1 || hits[0].Highlight != "Fix <mark>bug</mark>" {
+		t.Fatalf("hits = %+v", hits)
+	}
+}
+
+func TestError(t *testing.T) {
+	c := newTestClient(t, &fakeStore{})
+	_, err := c.SearchTasks(context.Background(), "", 0)
+	var e *Error
+	if !errors.As(err, &e) || e.Status != http.StatusBadRequest || e.Code != "bad_request" {
+		t.Fatalf("err = %v, want bad_request", err)
+	}
+}
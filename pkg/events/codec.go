@@ -0,0 +1,95 @@
+// Пакет events публикует события жизненного цикла задач из outbox
+// хранилища во внешние брокеры сообщений.
+package events
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"30-5/pkg/storage"
+)
+
+// Codec сериализует события для отправки в брокер.
+type Codec interface {
+	Encode(e storage.Event) ([]byte, error)
+	ContentType() string
+}
+
+// JSONCodec кодирует событие в JSON; состояние задачи
+// вкладывается как JSON-объект.
+type JSONCodec struct{}
+
+// Событие в формате JSON.
+type jsonEvent struct {
+	ID      int64           `json:"id"`
+	Created int64           `json:"created"`
+	Type    string          `json:"type"`
+	TaskID  int             `json:"task_id"`
+	Task    json.RawMessage `json:"task"`
+}
+
+func (JSONCodec) Encode(e storage.Event) ([]byte, error) {
+	return json.Marshal(jsonEvent{
+		ID:      e.ID,
+		Created: e.Created,
+		Type:    e.Type,
+		TaskID:  e.TaskID,
+		Task:    e.Payload,
+	})
+}
+
+func (JSONCodec) ContentType() string {
+	return "application/json"
+}
+
+// ProtoCodec кодирует событие в двоичный формат Protocol Buffers
+// по схеме:
+//
+//	message TaskEvent {
+//	  int64 id = 1;
+//	  int64 created = 2;
+//	  string type = 3;
+//	  int64 task_id = 4;
+//	  bytes task = 5; // состояние задачи в JSON
+//	}
+type ProtoCodec struct{}
+
+// Типы полей формата Protocol Buffers.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (ProtoCodec) Encode(e storage.Event) ([]byte, error) {
+	buf := make([]byte, 0, 32+len(e.Type)+len(e.Payload))
+	buf = appendVarintField(buf, 1, uint64(e.ID))
+	buf = appendVarintField(buf, 2, uint64(e.Created))
+	buf = appendBytesField(buf, 3, []byte(e.Type))
+	buf = appendVarintField(buf, 4, uint64(e.TaskID))
+	buf = appendBytesField(buf, 5, e.Payload)
+	return buf, nil
+}
+
+func (ProtoCodec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+// appendVarintField дописывает числовое поле; нулевые значения,
+// как принято в proto3, не записываются.
+func appendVarintField(buf []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(buf, v)
+}
+
+// appendBytesField дописывает строковое или двоичное поле.
+func appendBytesField(buf []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return buf
+	}
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(v)))
+	return append(buf, v...)
+}
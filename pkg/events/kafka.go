@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"strconv"
+
+	"30-5/pkg/storage"
+)
+
+// KafkaProducer отправляет сообщение в топик Kafka.
+// Реализуется адаптером над используемой клиентской библиотекой.
+type KafkaProducer interface {
+	Produce(ctx context.Context, topic string, key, value []byte, headers map[string]string) error
+}
+
+// KafkaPublisher публикует события задач в топик Kafka.
+// Ключом сообщения служит id задачи, так что события одной задачи
+// попадают в одну партицию и читаются в порядке возникновения.
+type KafkaPublisher struct {
+	producer KafkaProducer
+	topic    string
+	codec    Codec
+}
+
+// NewKafkaPublisher создаёт публикатор событий в топик topic.
+// Если codec не задан, события кодируются в JSON.
+func NewKafkaPublisher(producer KafkaProducer, topic string, codec Codec) *KafkaPublisher {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &KafkaPublisher{
+		producer: producer,
+		topic:    topic,
+		codec:    codec,
+	}
+}
+
+// Publish отправляет событие в Kafka. Используется как storage.Publisher
+// диспетчером outbox.
+func (p *KafkaPublisher) Publish(ctx context.Context, e storage.Event) error {
+	value, err := p.codec.Encode(e)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{
+		"content-type": p.codec.ContentType(),
+		"event-type":   e.Type,
+		"event-id":     strconv.FormatInt(e.ID, 10),
+	}
+	key := []byte(strconv.Itoa(e.TaskID))
+	return p.producer.Produce(ctx, p.topic, key, value, headers)
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"30-5/pkg/storage"
+)
+
+// JetStream публикует сообщение в поток NATS JetStream.
+// Реализуется адаптером над клиентом NATS; msgID передаётся
+// в заголовке Nats-Msg-Id для дедупликации повторных отправок.
+type JetStream interface {
+	Publish(ctx context.Context, subject string, data []byte, msgID string) error
+}
+
+// NATSPublisher публикует события задач в NATS JetStream.
+// Тема сообщения строится по типу события: "task.created"
+// публикуется в <prefix>.created.
+type NATSPublisher struct {
+	js     JetStream
+	prefix string
+	codec  Codec
+}
+
+// NewNATSPublisher создаёт публикатор событий в темы с префиксом prefix
+// (по умолчанию "tasks"). Если codec не задан, события кодируются в JSON.
+func NewNATSPublisher(js JetStream, prefix string, codec Codec) *NATSPublisher {
+	if prefix == "" {
+		prefix = "tasks"
+	}
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &NATSPublisher{
+		js:     js,
+		prefix: prefix,
+		codec:  codec,
+	}
+}
+
+// Subject возвращает тему NATS для события типа typ.
+func (p *NATSPublisher) Subject(typ string) string {
+	if i := strings.LastIndexByte(typ, '.'); i >= 0 {
+		typ = typ[i+1:]
+	}
+	return p.prefix + "." + typ
+}
+
+// Publish отправляет событие в JetStream.
+func (p *NATSPublisher) Publish(ctx context.Context, e storage.Event) error {
+	data, err := p.codec.Encode(e)
+	if err != nil {
+		return err
+	}
+	return p.js.Publish(ctx, p.Subject(e.Type), data, strconv.FormatInt(e.ID, 10))
+}
@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"30-5/pkg/storage"
+)
+
+// ErrNoPublisher возвращается, если в конфигурации не задан ни один брокер.
+var ErrNoPublisher = errors.New("no event publisher configured")
+
+// Multi рассылает каждое событие всем публикаторам по очереди.
+// Ошибка любого из них прерывает рассылку, и событие будет
+// отправлено повторно при следующем проходе диспетчера, поэтому
+// получатели должны быть готовы к дубликатам.
+type Multi []storage.Publisher
+
+// Publish отправляет событие всем публикаторам.
+func (m Multi) Publish(ctx context.Context, e storage.Event) error {
+	for _, p := range m {
+		if err := p.Publish(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Config описывает, в какие брокеры публиковать события.
+// Брокер используется, если задан его клиент.
+type Config struct {
+	Codec string // "json" (по умолчанию) или "protobuf"
+
+	Kafka      KafkaProducer
+	KafkaTopic string
+
+	NATS       JetStream
+	NATSPrefix string
+}
+
+// NewPublisher создаёт публикатор по конфигурации: в Kafka, в NATS
+// или в оба брокера сразу.
+func NewPublisher(cfg Config) (storage.Publisher, error) {
+	codec, err := codecByName(cfg.Codec)
+	if err != nil {
+		return nil, err
+	}
+	var m Multi
+	if cfg.Kafka != nil {
+		m = append(m, NewKafkaPublisher(cfg.Kafka, cfg.KafkaTopic, codec))
+	}
+	if cfg.NATS != nil {
+		m = append(m, NewNATSPublisher(cfg.NATS, cfg.NATSPrefix, codec))
+	}
+	switch len(m) {
+	case 0:
+		return nil, ErrNoPublisher
+	case 1:
+		return m[0], nil
+	}
+	return m, nil
+}
+
+// codecByName возвращает кодек по его имени в конфигурации.
+func codecByName(name string) (Codec, error) {
+	switch name {
+	case "", "json":
+		return JSONCodec{}, nil
+	case "protobuf", "proto":
+		return ProtoCodec{}, nil
+	}
+	return nil, fmt.Errorf("unknown event codec %q", name)
+}
@@ -0,0 +1,198 @@
+// Пакет middleware содержит декораторы хранилища задач.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"30-5/pkg/storage"
+
+	"github.com/jackc/pgconn"
+)
+
+// ErrUnavailable возвращается, пока предохранитель разомкнут
+// после серии отказов БД.
+var ErrUnavailable = errors.New("storage unavailable")
+
+// TaskPeeker возвращает задачу из кэша без обращения к БД,
+// например *cache.LRU.
+type TaskPeeker interface {
+	Peek(id int) (storage.Task, bool)
+}
+
+// Breaker - предохранитель: после threshold отказов подряд размыкается
+// и на время cooldown отвечает ErrUnavailable без обращения к БД.
+// Затем пропускает один пробный вызов: при успехе замыкается,
+// при отказе снова размыкается. Пробный вызов, прерванный отменой
+// или дедлайном контекста вызывающего, о БД ничего не говорит:
+// предохранитель остаётся в ожидании следующей пробы.
+// Отказом считаются ошибки соединения, таймауты и сбои сервера БД,
+// но не ошибки предметной области вроде storage.ErrTaskNotFound
+// или storage.ErrDuplicateUID.
+type Breaker struct {
+	storage.Interface
+
+	threshold int
+	cooldown  time.Duration
+
+	// Cached, если задан, отвечает на TaskByID при разомкнутом
+	// предохранителе данными из кэша.
+	Cached TaskPeeker
+
+	mu       sync.Mutex
+	failures int       // отказы подряд
+	openedAt time.Time // время размыкания, нулевое - замкнут
+	probing  bool      // выполняется пробный вызов
+}
+
+// NewBreaker оборачивает хранилище s предохранителем.
+func NewBreaker(s storage.Interface, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		Interface: s,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow сообщает, можно ли выполнить вызов и является ли он пробным.
+func (b *Breaker) allow() (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return true, false
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// done учитывает результат вызова; probe - вызов пробный.
+func (b *Breaker) done(err error, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+	}
+	if !isFailure(err) {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold || !b.openedAt.IsZero() {
+		b.openedAt = time.Now()
+	}
+}
+
+// isFailure сообщает, указывает ли ошибка на недоступность БД:
+// ошибку сети или соединения, таймаут или ошибку сервера из классов
+// нехватки ресурсов и сбоев. Прочие ошибки, в том числе ошибки
+// предметной области хранилища и нарушения ограничений, отказом
+// не считаются.
+func isFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 08 - соединение, 53 - нехватка ресурсов,
+		// 57 - вмешательство оператора, в том числе statement_timeout,
+		// 58 - системная ошибка, XX - внутренняя ошибка сервера
+		switch pgErr.Code[:2] {
+		case "08", "53", "57", "58", "XX":
+			return true
+		}
+		return false
+	}
+	if pgconn.Timeout(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// guard выполняет fn, если предохранитель замкнут.
+func guard[T any](b *Breaker, fn func() (T, error)) (T, error) {
+	ok, probe := b.allow()
+	if !ok {
+		var zero T
+		return zero, ErrUnavailable
+	}
+	v, err := fn()
+	b.done(err, probe)
+	return v, err
+}
+
+// Методы хранилища выполняются через предохранитель.
+
+func (b *Breaker) Tasks(ctx context.Context, taskID, authorID int) ([]storage.Task, error) {
+	return guard(b, func() ([]storage.Task, error) { return b.Interface.Tasks(ctx, taskID, authorID) })
+}
+
+// TaskByID при разомкнутом предохранителе отвечает из кэша Cached, если он задан.
+func (b *Breaker) TaskByID(ctx context.Context, id int) (storage.Task, error) {
+	t, err := guard(b, func() (storage.Task, error) { return b.Interface.TaskByID(ctx, id) })
+	if errors.Is(err, ErrUnavailable) && b.Cached != nil {
+		if cached, ok := b.Cached.Peek(id); ok {
+			return cached, nil
+		}
+	}
+	return t, err
+}
+
+func (b *Breaker) TasksByFilter(ctx context.Context, f storage.TaskFilter) ([]storage.Task, error) {
+	return guard(b, func() ([]storage.Task, error) { return b.Interface.TasksByFilter(ctx, f) })
+}
+
+func (b *Breaker) TaskByAuthor(ctx context.Context, authorID int) ([]storage.Task, error) {
+	return guard(b, func() ([]storage.Task, error) { return b.Interface.TaskByAuthor(ctx, authorID) })
+}
+
+func (b *Breaker) TaskByLabel(ctx context.Context, labelName string) ([]storage.Task, error) {
+	return guard(b, func() ([]storage.Task, error) { return b.Interface.TaskByLabel(ctx, labelName) })
+}
+
+func (b *Breaker) TaskLabels(ctx context.Context, taskID int) ([]storage.Label, error) {
+	return guard(b, func() ([]storage.Label, error) { return b.Interface.TaskLabels(ctx, taskID) })
+}
+
+func (b *Breaker) NewTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	return guard(b, func() (storage.Task, error) { return b.Interface.NewTask(ctx, t) })
+}
+
+func (b *Breaker) NewTaskWithLabels(ctx context.Context, t storage.Task, labels []string) (storage.Task, error) {
+	return guard(b, func() (storage.Task, error) { return b.Interface.NewTaskWithLabels(ctx, t, labels) })
+}
+
+func (b *Breaker) UpdateTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	return guard(b, func() (storage.Task, error) { return b.Interface.UpdateTask(ctx, t) })
+}
+
+func (b *Breaker) DeleteTask(ctx context.Context, id int) (storage.DeleteStats, error) {
+	return guard(b, func() (storage.DeleteStats, error) { return b.Interface.DeleteTask(ctx, id) })
+}
+
+func (b *Breaker) CloseTasks(ctx context.Context, ids []int) (storage.BatchResult, error) {
+	return guard(b, func() (storage.BatchResult, error) { return b.Interface.CloseTasks(ctx, ids) })
+}
+
+func (b *Breaker) ReassignTasks(ctx context.Context, ids []int, newAssignee int) (storage.BatchResult, error) {
+	return guard(b, func() (storage.BatchResult, error) { return b.Interface.ReassignTasks(ctx, ids, newAssignee) })
+}
+
+func (b *Breaker) AddLabelToTasks(ctx context.Context, labelName string, taskIDs []int) (storage.BatchResult, error) {
+	return guard(b, func() (storage.BatchResult, error) { return b.Interface.AddLabelToTasks(ctx, labelName, taskIDs) })
+}
+
+func (b *Breaker) RemoveLabelFromTasks(ctx context.Context, labelName string, taskIDs []int) (storage.BatchResult, error) {
+	return guard(b, func() (storage.BatchResult, error) { return b.Interface.RemoveLabelFromTasks(ctx, labelName, taskIDs) })
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"30-5/pkg/storage"
+
+	"github.com/jackc/pgconn"
+)
+
+func TestIsFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", context.Canceled, false},
+		{"wrapped canceled", fmt.Errorf("query: %w", context.Canceled), false},
+		{"task not found", storage.ErrTaskNotFound, false},
+		{"duplicate uid", storage.ErrDuplicateUID, false},
+		{"quota", storage.ErrQuotaExceeded, false},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"syntax error", &pgconn.PgError{Code: "42601"}, false},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"too many connections", &pgconn.PgError{Code: "53300"}, true},
+		{"statement timeout", &pgconn.PgError{Code: "57014"}, true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"io error", &pgconn.PgError{Code: "58030"}, true},
+		{"internal error", &pgconn.PgError{Code: "XX000"}, true},
+		{"wrapped pg error", fmt.Errorf("update: %w", &pgconn.PgError{Code: "08000"}), true},
+		{"deadline", context.DeadlineExceeded, true},
+		{"net error", &net.OpError{Op: "dial", Err: errors.New("refused")}, true},
+		{"eof", io.EOF, true},
+		{"unexpected eof", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"other", errors.New("something else"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFailure(tt.err); got != tt.want {
+				t.Errorf("isFailure(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// stubStorage отвечает на TaskByID ошибкой err и считает вызовы.
+type stubStorage struct {
+	storage.Interface
+	err   error
+	calls int
+}
+
+func (s *stubStorage) TaskByID(ctx context.Context, id int) (storage.Task, error) {
+	s.calls++
+	return storage.Task{ID: id}, s.err
+}
+
+// peeker - кэш с одной задачей.
+type peeker struct{ t storage.Task }
+
+func (p peeker) Peek(id int) (storage.Task, bool) {
+	return p.t, id == p.t.ID
+}
+
+func TestBreaker(t *testing.T) {
+	down := &pgconn.PgError{Code: "08006"}
+	const cooldown = 20 * time.Millisecond
+	// шаг: ошибка хранилища, пауза перед вызовом и ожидаемый результат
+	steps := []struct {
+		name     string
+		err      error
+		wait     time.Duration
+		wantErr  error
+		wantCall bool
+	}{
+		{"success", nil, 0, nil, true},
+		{"domain error is not a failure", storage.ErrTaskNotFound, 0, storage.ErrTaskNotFound, true},
+		{"first failure", down, 0, down, true},
+		{"second failure", down, 0, down, true},
+		{"third failure opens", down, 0, down, true},
+		{"open", nil, 0, ErrUnavailable, false},
+		{"probe fails and reopens", down, cooldown, down, true},
+		{"open again", nil, 0, ErrUnavailable, false},
+		{"canceled probe keeps half-open", context.Canceled, cooldown, context.Canceled, true},
+		{"timed out probe keeps half-open", context.DeadlineExceeded, 0, context.DeadlineExceeded, true},
+		{"next probe fails and reopens", down, 0, down, true},
+		{"open after probe", nil, 0, ErrUnavailable, false},
+		{"probe succeeds and closes", nil, cooldown, nil, true},
+		{"closed", down, 0, down, true},
+		{"one failure keeps closed", nil, 0, nil, true},
+	}
+	st := &stubStorage{}
+	b := NewBreaker(st, 3, cooldown)
+	ctx := context.Background()
+	for _, s := range steps {
+		time.Sleep(s.wait)
+		st.err = s.err
+		calls := st.calls
+		_, err := b.TaskByID(ctx, 1)
+		if !errors.Is(err, s.wantErr) || (err == nil) != (s.wantErr == nil) {
+			t.Fatalf("%s: err = %v, want %v", s.name, err, s.wantErr)
+		}
+		if called := st.calls > calls; called != s.wantCall {
+			t.Fatalf("%s: storage called = %v, want %v", s.name, called, s.wantCall)
+		}
+	}
+}
+
+func TestBreakerCachedFallback(t *testing.T) {
+	st := &stubStorage{err: &pgconn.PgError{Code: "08006"}}
+	b := NewBreaker(st, 1, time.Hour)
+	b.Cached = peeker{storage.Task{ID: 7, Title: "cached"}}
+	ctx := context.Background()
+	if _, err := b.TaskByID(ctx, 7); err == nil {
+		t.Fatal("first call: want storage error")
+	}
+	got, err := b.TaskByID(ctx, 7)
+	if err != nil || got.Title != "cached" {
+		t.Fatalf("open breaker, cached task = %+v, %v", got, err)
+	}
+	if _, err = b.TaskByID(ctx, 8); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("open breaker, uncached task: err = %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"30-5/pkg/cache"
+	"30-5/pkg/storage"
+)
+
+// Middleware оборачивает хранилище декоратором.
+type Middleware func(storage.Interface) storage.Interface
+
+// Chain оборачивает хранилище s декораторами mws.
+// Первый декоратор списка оказывается внешним: Chain(s, a, b)
+// равносильно a(b(s)).
+func Chain(s storage.Interface, mws ...Middleware) storage.Interface {
+	for i := len(mws) - 1; i >= 0; i-- {
+		s = mws[i](s)
+	}
+	return s
+}
+
+// WithBreaker - предохранитель, см. NewBreaker.
+func WithBreaker(threshold int, cooldown time.Duration) Middleware {
+	return func(s storage.Interface) storage.Interface {
+		return NewBreaker(s, threshold, cooldown)
+	}
+}
+
+// WithLRU - кэш в памяти процесса на size записей, см. cache.NewLRU.
+func WithLRU(size int) Middleware {
+	return func(s storage.Interface) storage.Interface {
+		return cache.NewLRU(s, size)
+	}
+}
+
+// WithRedis - кэш в Redis, см. cache.NewRedis.
+func WithRedis(client cache.RedisClient, taskTTL, listTTL time.Duration) Middleware {
+	return func(s storage.Interface) storage.Interface {
+		return cache.NewRedis(s, client, taskTTL, listTTL)
+	}
+}
+
+// Around выполняет вызов call метода хранилища op
+// и возвращает его ошибку, возможно, изменённую.
+type Around func(ctx context.Context, op string, call func() error) error
+
+// Wrap создаёт декоратор, выполняющий каждый метод хранилища через around.
+func Wrap(around Around) Middleware {
+	return func(s storage.Interface) storage.Interface {
+		return &hooked{Interface: s, around: around}
+	}
+}
+
+// Декоратор, вызывающий методы хранилища через around.
+type hooked struct {
+	storage.Interface
+	around Around
+}
+
+// run выполняет fn через around декоратора h.
+func run[T any](h *hooked, ctx context.Context, op string, fn func() (T, error)) (T, error) {
+	var v T
+	err := h.around(ctx, op, func() error {
+		var err error
+		v, err = fn()
+		return err
+	})
+	return v, err
+}
+
+func (h *hooked) Tasks(ctx context.Context, taskID, authorID int) ([]storage.Task, error) {
+	return run(h, ctx, "Tasks", func() ([]storage.Task, error) { return h.Interface.Tasks(ctx, taskID, authorID) })
+}
+
+func (h *hooked) TaskByID(ctx context.Context, id int) (storage.Task, error) {
+	return run(h, ctx, "TaskByID", func() (storage.Task, error) { return h.Interface.TaskByID(ctx, id) })
+}
+
+func (h *hooked) TasksByFilter(ctx context.Context, f storage.TaskFilter) ([]storage.Task, error) {
+	return run(h, ctx, "TasksByFilter", func() ([]storage.Task, error) { return h.Interface.TasksByFilter(ctx, f) })
+}
+
+func (h *hooked) TaskByAuthor(ctx context.Context, authorID int) ([]storage.Task, error) {
+	return run(h, ctx, "TaskByAuthor", func() ([]storage.Task, error) { return h.Interface.TaskByAuthor(ctx, authorID) })
+}
+
+func (h *hooked) TaskByLabel(ctx context.Context, labelName string) ([]storage.Task, error) {
+	return run(h, ctx, "TaskByLabel", func() ([]storage.Task, error) { return h.Interface.TaskByLabel(ctx, labelName) })
+}
+
+func (h *hooked) TaskLabels(ctx context.Context, taskID int) ([]storage.Label, error) {
+	return run(h, ctx, "TaskLabels", func() ([]storage.Label, error) { return h.Interface.TaskLabels(ctx, taskID) })
+}
+
+func (h *hooked) NewTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	return run(h, ctx, "NewTask", func() (storage.Task, error) { return h.Interface.NewTask(ctx, t) })
+}
+
+func (h *hooked) NewTaskWithLabels(ctx context.Context, t storage.Task, labels []string) (storage.Task, error) {
+	return run(h, ctx, "NewTaskWithLabels", func() (storage.Task, error) { return h.Interface.NewTaskWithLabels(ctx, t, labels) })
+}
+
+func (h *hooked) UpdateTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	return run(h, ctx, "UpdateTask", func() (storage.Task, error) { return h.Interface.UpdateTask(ctx, t) })
+}
+
+func (h *hooked) DeleteTask(ctx context.Context, id int) (storage.DeleteStats, error) {
+	return run(h, ctx, "DeleteTask", func() (storage.DeleteStats, error) { return h.Interface.DeleteTask(ctx, id) })
+}
+
+func (h *hooked) CloseTasks(ctx context.Context, ids []int) (storage.BatchResult, error) {
+	return run(h, ctx, "CloseTasks", func() (storage.BatchResult, error) { return h.Interface.CloseTasks(ctx, ids) })
+}
+
+func (h *hooked) ReassignTasks(ctx context.Context, ids []int, newAssignee int) (storage.BatchResult, error) {
+	return run(h, ctx, "ReassignTasks", func() (storage.BatchResult, error) { return h.Interface.ReassignTasks(ctx, ids, newAssignee) })
+}
+
+func (h *hooked) AddLabelToTasks(ctx context.Context, labelName string, taskIDs []int) (storage.BatchResult, error) {
+	return run(h, ctx, "AddLabelToTasks", func() (storage.BatchResult, error) { return h.Interface.AddLabelToTasks(ctx, labelName, taskIDs) })
+}
+
+func (h *hooked) RemoveLabelFromTasks(ctx context.Context, labelName string, taskIDs []int) (storage.BatchResult, error) {
+	return run(h, ctx, "RemoveLabelFromTasks", func() (storage.BatchResult, error) { return h.Interface.RemoveLabelFromTasks(ctx, labelName, taskIDs) })
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"expvar"
+	"time"
+)
+
+// Logger - получатель журнала вызовов, например *log.Logger.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// Logging записывает в журнал каждый вызов хранилища
+// с его длительностью и ошибкой.
+func Logging(l Logger) Middleware {
+	return Wrap(func(ctx context.Context, op string, call func() error) error {
+		start := time.Now()
+		err := call()
+		if err != nil {
+			l.Printf("storage: %s %s: %v", op, time.Since(start), err)
+		} else {
+			l.Printf("storage: %s %s", op, time.Since(start))
+		}
+		return err
+	})
+}
+
+// Metrics публикует через expvar под именем name счётчики вызовов
+// (<op>.calls), ошибок (<op>.errors) и суммарное время в наносекундах
+// (<op>.ns) для каждого метода хранилища.
+// Имя должно быть уникальным в процессе.
+func Metrics(name string) Middleware {
+	m := expvar.NewMap(name)
+	return Wrap(func(ctx context.Context, op string, call func() error) error {
+		start := time.Now()
+		err := call()
+		m.Add(op+".calls", 1)
+		m.Add(op+".ns", int64(time.Since(start)))
+		if err != nil {
+			m.Add(op+".errors", 1)
+		}
+		return err
+	})
+}
+
+// методы хранилища, не изменяющие данные: их безопасно повторять
+var readOps = map[string]bool{
+	"Tasks":         true,
+	"TaskByID":      true,
+	"TasksByFilter": true,
+	"TaskByAuthor":  true,
+	"TaskByLabel":   true,
+	"TaskLabels":    true,
+}
+
+// Retry повторяет до attempts раз чтение, завершившееся отказом БД,
+// с удваивающейся паузой начиная с backoff.
+// Изменяющие методы не повторяются: отказ мог произойти после фиксации.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return Wrap(func(ctx context.Context, op string, call func() error) error {
+		err := call()
+		if !readOps[op] {
+			return err
+		}
+		delay := backoff
+		for i := 1; i < attempts && isFailure(err); i++ {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(delay):
+			}
+			delay *= 2
+			err = call()
+		}
+		return err
+	})
+}
@@ -0,0 +1,115 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"30-5/pkg/storage"
+)
+
+// Виды фоновых заданий экспорта.
+const (
+	JobExportXLSX     = "export.xlsx"
+	JobExportMarkdown = "export.markdown"
+)
+
+// PermissionChecker проверяет права пользователя,
+// например *storage.Storage.
+type PermissionChecker interface {
+	CheckPermission(ctx context.Context, userID int, action storage.Action, taskID int) error
+}
+
+// Параметры выборки задач для заданий экспорта: допустимые поля
+// storage.TaskFilter с теми же именами в JSON.
+type exportFilter struct {
+	AuthorID     int
+	AssignedID   int
+	Label        string
+	EpicID       int
+	Drafts       bool
+	Order        storage.Order
+	OpenedAfter  int64
+	OpenedBefore int64
+	ClosedAfter  int64
+	ClosedBefore int64
+	UpdatedAfter int64
+}
+
+// filter возвращает фильтр выборки. Черновики всех авторов выбираются,
+// только если пользователю из контекста разрешено изменять любые
+// задачи; иначе черновики исключаются.
+func (e exportFilter) filter(ctx context.Context, pc PermissionChecker) (storage.TaskFilter, error) {
+	f := storage.TaskFilter{
+		AuthorID:     e.AuthorID,
+		AssignedID:   e.AssignedID,
+		Label:        e.Label,
+		EpicID:       e.EpicID,
+		Order:        e.Order,
+		OpenedAfter:  e.OpenedAfter,
+		OpenedBefore: e.OpenedBefore,
+		ClosedAfter:  e.ClosedAfter,
+		ClosedBefore: e.ClosedBefore,
+		UpdatedAfter: e.UpdatedAfter,
+	}
+	if e.Drafts {
+		err := pc.CheckPermission(ctx, storage.Actor(ctx), storage.ActionUpdate, 0)
+		if err != nil && !errors.Is(err, storage.ErrForbidden) {
+			return storage.TaskFilter{}, err
+		}
+		f.Drafts = err == nil
+	}
+	return f, nil
+}
+
+// Jobs возвращает обработчики фоновых заданий экспорта для
+// storage.RunJobs. Параметры задания - поля фильтра storage.TaskFilter
+// (AuthorID, AssignedID, Label, EpicID, Drafts, Order и границы периодов)
+// в JSON, для Markdown также поля MarkdownOptions; прочие поля
+// игнорируются. Права на черновики проверяются через pc.
+func Jobs(src Source, pc PermissionChecker) map[string]storage.JobHandler {
+	return map[string]storage.JobHandler{
+		JobExportXLSX: func(ctx context.Context, params json.RawMessage, progress func(done, total int)) (storage.JobResult, error) {
+			var p exportFilter
+			if err := json.Unmarshal(params, &p); err != nil {
+				return storage.JobResult{}, err
+			}
+			f, err := p.filter(ctx, pc)
+			if err != nil {
+				return storage.JobResult{}, err
+			}
+			progress(0, 1)
+			var buf bytes.Buffer
+			if err := WriteXLSX(ctx, src, f, &buf); err != nil {
+				return storage.JobResult{}, err
+			}
+			return storage.JobResult{
+				ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+				Data:        buf.Bytes(),
+			}, nil
+		},
+		JobExportMarkdown: func(ctx context.Context, params json.RawMessage, progress func(done, total int)) (storage.JobResult, error) {
+			var p struct {
+				exportFilter
+				Title   string
+				GroupBy Grouping
+				TaskURL string
+			}
+			if err := json.Unmarshal(params, &p); err != nil {
+				return storage.JobResult{}, err
+			}
+			f, err := p.filter(ctx, pc)
+			if err != nil {
+				return storage.JobResult{}, err
+			}
+			progress(0, 1)
+			var buf bytes.Buffer
+			opts := MarkdownOptions{Title: p.Title, GroupBy: p.GroupBy, TaskURL: p.TaskURL}
+			if err := WriteMarkdown(ctx, src, f, &buf, opts); err != nil {
+				return storage.JobResult{}, err
+			}
+			return storage.JobResult{ContentType: "text/markdown; charset=utf-8", Data: buf.Bytes()}, nil
+		},
+	}
+}
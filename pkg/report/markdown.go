@@ -0,0 +1,159 @@
+package report
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"30-5/pkg/storage"
+)
+
+// Группировка задач в отчёте.
+type Grouping int
+
+const (
+	// GroupByLabel - по меткам: задача с несколькими метками
+	// попадает в каждую из групп.
+	GroupByLabel Grouping = iota
+	// GroupByAssignee - по ответственным.
+	GroupByAssignee
+)
+
+// Параметры отчёта в формате Markdown.
+type MarkdownOptions struct {
+	Title   string   // заголовок документа, "" - без заголовка
+	GroupBy Grouping // группировка задач
+	// TaskURL - шаблон ссылки на задачу с %d на месте id,
+	// например "https://tracker.example.com/tasks/%d"; "" - без ссылок.
+	TaskURL string
+	// Location - часовой пояс дат, nil - UTC.
+	Location *time.Location
+}
+
+// noAssignee - название группы задач без ответственного.
+const noAssignee = "Без ответственного"
+
+// WriteMarkdown записывает в w документ Markdown с задачами, подходящими
+// под фильтр, сгруппированными по меткам или ответственным. Каждая задача -
+// пункт списка с отметкой выполнения, ссылкой и статусом; документ
+// подходит для вставки в вики и примечания к выпуску.
+// Задачи выбираются так же, как для отчёта XLSX, так что оба отчёта
+// по одному фильтру совпадают; задача с несколькими метками попадает
+// в группу каждой из них.
+func WriteMarkdown(ctx context.Context, src Source, f storage.TaskFilter, w io.Writer, opts MarkdownOptions) error {
+	rows, err := load(ctx, src, f)
+	if err != nil {
+		return err
+	}
+	var groups map[string][]row
+	var names []string
+	if opts.GroupBy == GroupByAssignee {
+		groups, names = byAssignee(rows)
+	} else {
+		groups, names = byLabel(rows)
+	}
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := time.Now().Unix()
+
+	bw := bufio.NewWriter(w)
+	if opts.Title != "" {
+		fmt.Fprintf(bw, "# %s\n\n", mdEscape(opts.Title))
+	}
+	for _, name := range names {
+		fmt.Fprintf(bw, "## %s (%d)\n\n", mdEscape(name), len(groups[name]))
+		for _, r := range groups[name] {
+			writeMarkdownTask(bw, r, opts, loc, now)
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// writeMarkdownTask записывает задачу пунктом списка.
+func writeMarkdownTask(w *bufio.Writer, r row, opts MarkdownOptions, loc *time.Location, now int64) {
+	mark := " "
+	if r.Closed != 0 {
+		mark = "x"
+	}
+	title := fmt.Sprintf("#%d %s", r.ID, mdEscape(r.Title))
+	if opts.TaskURL != "" {
+		title = fmt.Sprintf("[%s](%s)", title, fmt.Sprintf(opts.TaskURL, r.ID))
+	}
+	details := []string{status(r.Task, loc, now)}
+	if opts.GroupBy == GroupByAssignee {
+		if len(r.Labels) > 0 {
+			labels := make([]string, len(r.Labels))
+			for i, l := range r.Labels {
+				// внутри кода экранирование не действует,
+				// поэтому обратные кавычки заменяются
+				labels[i] = "`" + strings.ReplaceAll(l.Name, "`", "'") + "`"
+			}
+			details = append(details, strings.Join(labels, " "))
+		}
+	} else if r.Assignee.Name != "" {
+		details = append(details, mdEscape(r.Assignee.Name))
+	}
+	fmt.Fprintf(w, "- [%s] %s — %s\n", mark, title, strings.Join(details, ", "))
+}
+
+// status возвращает статус задачи для отчёта.
+func status(t storage.Task, loc *time.Location, now int64) string {
+	switch {
+	case t.Closed != 0:
+		return "выполнена " + date(t.Closed, loc)
+	case t.Due != 0 && t.Due < now:
+		return "просрочена, срок " + date(t.Due, loc)
+	case t.Due != 0:
+		return "открыта, срок " + date(t.Due, loc)
+	default:
+		return "открыта"
+	}
+}
+
+// date форматирует время Unix как дату в часовом поясе loc.
+func date(unix int64, loc *time.Location) string {
+	return time.Unix(unix, 0).In(loc).Format("02.01.2006")
+}
+
+// byAssignee группирует строки по именам ответственных.
+// Возвращает группы и их названия по алфавиту, группа задач
+// без ответственного идёт последней.
+func byAssignee(rows []row) (map[string][]row, []string) {
+	groups := make(map[string][]row)
+	for _, r := range rows {
+		name := r.Assignee.Name
+		if r.AssignedID == 0 || name == "" {
+			name = noAssignee
+		}
+		groups[name] = append(groups[name], r)
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		if name != noAssignee {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := groups[noAssignee]; ok {
+		names = append(names, noAssignee)
+	}
+	return groups, names
+}
+
+// mdEscaper экранирует символы разметки Markdown в тексте.
+var mdEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+	`<`, `\<`, `>`, `\>`, `#`, `\#`, `|`, `\|`, "\n", " ",
+)
+
+// mdEscape экранирует текст для вставки в документ Markdown.
+func mdEscape(s string) string {
+	return mdEscaper.Replace(s)
+}
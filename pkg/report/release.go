@@ -0,0 +1,142 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/template"
+	"time"
+
+	"30-5/pkg/storage"
+)
+
+// Раздел примечаний к выпуску: задачи с одной из меток Labels.
+type Category struct {
+	Title  string
+	Labels []string
+}
+
+// Параметры примечаний к выпуску.
+type ReleaseOptions struct {
+	Version string
+	// Период выполнения задач: нижняя граница включается, верхняя - нет;
+	// нулевые границы период не ограничивают.
+	From, To time.Time
+	// EpicID - эпик выпуска, 0 - задачи всех эпиков.
+	EpicID int
+	// Categories - разделы в порядке вывода; задача попадает в первый
+	// раздел, одну из меток которого она имеет, иначе - в раздел Other.
+	Categories []Category
+	Other      string // название прочих задач, "" - "Прочее"
+	// TaskURL - шаблон ссылки на задачу с %d на месте id, "" - без ссылок.
+	TaskURL string
+	// Template - шаблон документа, nil - ReleaseTemplate.
+	// Шаблон получает значение ReleaseNotes.
+	Template *template.Template
+}
+
+// Данные шаблона примечаний к выпуску.
+type ReleaseNotes struct {
+	Version  string
+	From, To time.Time
+	Sections []ReleaseSection // непустые разделы
+}
+
+// Раздел примечаний к выпуску.
+type ReleaseSection struct {
+	Title string
+	Tasks []ReleaseTask // в порядке выполнения
+}
+
+// Задача в примечаниях к выпуску.
+type ReleaseTask struct {
+	ID       int
+	Title    string
+	URL      string // ссылка на задачу, "" - без ссылки
+	Closed   time.Time
+	Assignee string
+	Labels   []string
+}
+
+// ReleaseTemplate - шаблон примечаний к выпуску по умолчанию в формате Markdown.
+var ReleaseTemplate = template.Must(template.New("release").Funcs(template.FuncMap{
+	"md": mdEscape,
+}).Parse(`# {{if .Version}}Выпуск {{md .Version}}{{else}}Примечания к выпуску{{end}}
+{{range .Sections}}
+## {{md .Title}}
+{{range .Tasks}}
+- {{if .URL}}[#{{.ID}}]({{.URL}}){{else}}#{{.ID}}{{end}} {{md .Title}}
+{{- end}}
+{{end}}`))
+
+// WriteReleaseNotes записывает в w примечания к выпуску: задачи,
+// выполненные за период и, если задан, относящиеся к эпику выпуска,
+// по разделам opts.Categories, отображённые шаблоном opts.Template.
+func WriteReleaseNotes(ctx context.Context, src Source, w io.Writer, opts ReleaseOptions) error {
+	f := storage.TaskFilter{EpicID: opts.EpicID, ClosedAfter: 1, Snoozed: true}
+	if !opts.From.IsZero() && opts.From.Unix() > 1 {
+		f.ClosedAfter = opts.From.Unix()
+	}
+	if !opts.To.IsZero() {
+		f.ClosedBefore = opts.To.Unix()
+	}
+	rows, err := load(ctx, src, f)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Closed < rows[j].Closed })
+
+	other := opts.Other
+	if other == "" {
+		other = "Прочее"
+	}
+	sections := make([]ReleaseSection, len(opts.Categories)+1)
+	for i, c := range opts.Categories {
+		sections[i].Title = c.Title
+	}
+	sections[len(opts.Categories)].Title = other
+	for _, r := range rows {
+		t := ReleaseTask{
+			ID:       r.ID,
+			Title:    r.Title,
+			Closed:   time.Unix(r.Closed, 0),
+			Assignee: r.Assignee.Name,
+		}
+		if opts.TaskURL != "" {
+			t.URL = fmt.Sprintf(opts.TaskURL, r.ID)
+		}
+		for _, l := range r.Labels {
+			t.Labels = append(t.Labels, l.Name)
+		}
+		i := category(opts.Categories, t.Labels)
+		sections[i].Tasks = append(sections[i].Tasks, t)
+	}
+
+	notes := ReleaseNotes{Version: opts.Version, From: opts.From, To: opts.To}
+	for _, s := range sections {
+		if len(s.Tasks) > 0 {
+			notes.Sections = append(notes.Sections, s)
+		}
+	}
+	tmpl := opts.Template
+	if tmpl == nil {
+		tmpl = ReleaseTemplate
+	}
+	return tmpl.Execute(w, notes)
+}
+
+// category возвращает номер первого раздела, одну из меток которого
+// имеет задача с метками labels, или len(categories) - раздел прочих.
+func category(categories []Category, labels []string) int {
+	for i, c := range categories {
+		for _, want := range c.Labels {
+			for _, l := range labels {
+				if l == want {
+					return i
+				}
+			}
+		}
+	}
+	return len(categories)
+}
@@ -0,0 +1,324 @@
+// Пакет report формирует отчёты по задачам из хранилища.
+package report
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"30-5/pkg/storage"
+)
+
+// Source - источник задач для отчётов.
+type Source interface {
+	TaskDetails(ctx context.Context, f storage.TaskFilter) ([]storage.TaskDetail, error)
+	TasksWithLabels(ctx context.Context, f storage.TaskFilter) ([]storage.LabeledTask, error)
+}
+
+// Строка отчёта: задача со сведениями о людях и метками.
+type row struct {
+	storage.TaskDetail
+	Labels []storage.Label
+}
+
+// load выбирает задачи по фильтру вместе с именами людей и метками.
+// Отложенные задачи в отчёты включаются всегда.
+func load(ctx context.Context, src Source, f storage.TaskFilter) ([]row, error) {
+	f.Snoozed = true
+	details, err := src.TaskDetails(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	labeled, err := src.TasksWithLabels(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[int][]storage.Label, len(labeled))
+	for _, lt := range labeled {
+		labels[lt.ID] = lt.Labels
+	}
+	rows := make([]row, len(details))
+	for i, d := range details {
+		rows[i] = row{TaskDetail: d, Labels: labels[d.ID]}
+	}
+	return rows, nil
+}
+
+// noLabel - название группы задач без меток.
+const noLabel = "Без меток"
+
+// byLabel группирует строки по меткам: задача с несколькими метками
+// попадает в каждую из групп. Возвращает группы и их названия по алфавиту,
+// группа задач без меток идёт последней.
+func byLabel(rows []row) (map[string][]row, []string) {
+	groups := make(map[string][]row)
+	for _, r := range rows {
+		if len(r.Labels) == 0 {
+			groups[noLabel] = append(groups[noLabel], r)
+		}
+		for _, l := range r.Labels {
+			groups[l.Name] = append(groups[l.Name], r)
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		if name != noLabel {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := groups[noLabel]; ok {
+		names = append(names, noLabel)
+	}
+	return groups, names
+}
+
+// WriteXLSX записывает в w книгу Excel с задачами, подходящими под фильтр:
+// по листу на каждую метку, с заголовками столбцов и строкой итогов.
+func WriteXLSX(ctx context.Context, src Source, f storage.TaskFilter, w io.Writer) error {
+	rows, err := load(ctx, src, f)
+	if err != nil {
+		return err
+	}
+	groups, names := byLabel(rows)
+	if len(names) == 0 {
+		// в книге должен быть хотя бы один лист
+		names = []string{noLabel}
+	}
+
+	used := make(map[string]bool)
+	sheetNames := make([]string, len(names))
+	for i, name := range names {
+		sheetNames[i] = sheetName(name, used)
+	}
+
+	// описание типов содержимого должно идти в архиве первым
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypesXML(len(names))},
+		{"_rels/.rels", relsXML},
+		{"xl/workbook.xml", workbookXML(sheetNames)},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML(len(names))},
+		{"xl/styles.xml", stylesXML},
+	}
+	for _, p := range parts {
+		if err = writePart(zw, p.name, p.content); err != nil {
+			return err
+		}
+	}
+	for i, name := range names {
+		err = writePart(zw, fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheetXML(groups[name]))
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writePart добавляет в архив книги файл name.
+func writePart(zw *zip.Writer, name, content string) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(fw, content)
+	return err
+}
+
+// sheetName приводит название метки к допустимому уникальному имени листа:
+// не длиннее 31 символа и без символов []:*?/\.
+func sheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	base := []rune(name)
+	if len(base) > 31 {
+		base = base[:31]
+	}
+	name = string(base)
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		cut := base
+		if len(cut)+len(suffix) > 31 {
+			cut = cut[:31-len(suffix)]
+		}
+		name = string(cut) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// Стили ячеек из styles.xml.
+const (
+	styleNormal = 0
+	styleBold   = 1
+	styleDate   = 2
+)
+
+// Столбцы листа и их ширина.
+var columns = []struct {
+	title string
+	width int
+}{
+	{"ID", 8},
+	{"Задача", 40},
+	{"Автор", 20},
+	{"Ответственный", 20},
+	{"Создана", 12},
+	{"Срок", 12},
+	{"Выполнена", 12},
+	{"Приоритет", 10},
+	{"Оценка", 10},
+}
+
+// sheetXML формирует лист с задачами группы и строкой итогов.
+func sheetXML(rows []row) string {
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// закреплённая строка заголовков
+	sb.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+		`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+		`</sheetView></sheetViews>`)
+	sb.WriteString(`<cols>`)
+	for i, c := range columns {
+		fmt.Fprintf(&sb, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, c.width)
+	}
+	sb.WriteString(`</cols><sheetData>`)
+
+	sb.WriteString(`<row r="1">`)
+	for i, c := range columns {
+		strCell(&sb, i, 1, c.title, styleBold)
+	}
+	sb.WriteString(`</row>`)
+
+	var closed, points int
+	for i, r := range rows {
+		n := i + 2
+		fmt.Fprintf(&sb, `<row r="%d">`, n)
+		numCell(&sb, 0, n, float64(r.ID), styleNormal)
+		strCell(&sb, 1, n, r.Title, styleNormal)
+		strCell(&sb, 2, n, r.Author.Name, styleNormal)
+		strCell(&sb, 3, n, r.Assignee.Name, styleNormal)
+		dateCell(&sb, 4, n, r.Opened)
+		dateCell(&sb, 5, n, r.Due)
+		dateCell(&sb, 6, n, r.Closed)
+		numCell(&sb, 7, n, float64(r.Priority), styleNormal)
+		numCell(&sb, 8, n, float64(r.Estimate), styleNormal)
+		sb.WriteString(`</row>`)
+		if r.Closed != 0 {
+			closed++
+		}
+		points += r.Estimate
+	}
+
+	// итоги: число задач, число выполненных и сумма оценок
+	n := len(rows) + 2
+	last := n - 1
+	fmt.Fprintf(&sb, `<row r="%d">`, n)
+	strCell(&sb, 1, n, fmt.Sprintf("Итого: %d", len(rows)), styleBold)
+	fmt.Fprintf(&sb, `<c r="%s" s="%d"><f>COUNT(G2:G%d)</f><v>%d</v></c>`, ref(6, n), styleBold, last, closed)
+	fmt.Fprintf(&sb, `<c r="%s" s="%d"><f>SUM(I2:I%d)</f><v>%d</v></c>`, ref(8, n), styleBold, last, points)
+	sb.WriteString(`</row>`)
+
+	sb.WriteString(`</sheetData></worksheet>`)
+	return sb.String()
+}
+
+// ref возвращает адрес ячейки по номеру столбца (с 0) и строки (с 1).
+func ref(col, row int) string {
+	return fmt.Sprintf("%c%d", 'A'+col, row)
+}
+
+// strCell записывает текстовую ячейку.
+func strCell(sb *strings.Builder, col, row int, s string, style int) {
+	fmt.Fprintf(sb, `<c r="%s" t="inlineStr" s="%d"><is><t xml:space="preserve">`, ref(col, row), style)
+	xml.EscapeText(sb, []byte(s))
+	sb.WriteString(`</t></is></c>`)
+}
+
+// numCell записывает числовую ячейку.
+func numCell(sb *strings.Builder, col, row int, v float64, style int) {
+	fmt.Fprintf(sb, `<c r="%s" s="%d"><v>%s</v></c>`, ref(col, row), style, strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+// dateCell записывает дату по времени Unix; нулевое время даёт пустую ячейку.
+func dateCell(sb *strings.Builder, col, row int, unix int64) {
+	if unix == 0 {
+		return
+	}
+	// даты Excel - число дней от 30.12.1899
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	days := time.Unix(unix, 0).UTC().Sub(epoch).Hours() / 24
+	numCell(sb, col, row, days, styleDate)
+}
+
+func contentTypesXML(sheets int) string {
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&sb, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	sb.WriteString(`</Types>`)
+	return sb.String()
+}
+
+const relsXML = xml.Header +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+func workbookXML(names []string) string {
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, name := range names {
+		sb.WriteString(`<sheet name="`)
+		xml.EscapeText(&sb, []byte(name))
+		fmt.Fprintf(&sb, `" sheetId="%d" r:id="rId%d"/>`, i+1, i+1)
+	}
+	sb.WriteString(`</sheets></workbook>`)
+	return sb.String()
+}
+
+func workbookRelsXML(sheets int) string {
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&sb, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&sb, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	sb.WriteString(`</Relationships>`)
+	return sb.String()
+}
+
+// Стили: обычный, полужирный (заголовки и итоги) и дата.
+const stylesXML = xml.Header +
+	`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font>` +
+	`<font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill>` +
+	`<fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="14" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs></styleSheet>`
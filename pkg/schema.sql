@@ -3,18 +3,81 @@
     отслеживания выполнения задач.
 */
 
-DROP TABLE IF EXISTS tasks_labels, tasks, labels, users;
+DROP TABLE IF EXISTS schema_version, task_summaries, jobs, replica_positions, checklist_items, task_stars, assignment_rules, notification_prefs, audit_log, user_identities, sessions, api_keys, user_roles, label_stats, outbox, tasks_labels, tasks, epics, labels, users;
 
 -- пользователи системы
 CREATE TABLE users (
     id SERIAL PRIMARY KEY,
-    name TEXT NOT NULL
+    name TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    password_hash TEXT NOT NULL DEFAULT '', -- хэш пароля в формате PHC, '' - пароль не задан
+    time_zone TEXT NOT NULL DEFAULT 'UTC' -- часовой пояс IANA для сроков выполнения
 );
+-- адрес электронной почты служит именем для входа
+CREATE UNIQUE INDEX users_email_idx ON users (lower(email)) WHERE email <> '';
+
+-- роли пользователей для проверки прав доступа,
+-- пользователь без роли имеет права viewer
+CREATE TABLE user_roles (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('admin', 'manager', 'member', 'viewer'))
+);
+
+-- ключи API пользователей, хранится только SHA-256 ключа
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- владелец
+    name TEXT NOT NULL DEFAULT '', -- назначение ключа
+    prefix TEXT NOT NULL, -- начало ключа для опознания в списке
+    hash BYTEA NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}', -- разрешённые области
+    created BIGINT NOT NULL DEFAULT extract(epoch from now()),
+    last_used BIGINT NOT NULL DEFAULT 0,
+    revoked BIGINT NOT NULL DEFAULT 0 -- время отзыва, 0 - действует
+);
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
+
+-- настройки уведомлений пользователя, без строки - значения по умолчанию
+CREATE TABLE notification_prefs (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    channel TEXT NOT NULL DEFAULT 'email' CHECK (channel IN ('email', 'slack', 'none')),
+    digest TEXT NOT NULL DEFAULT 'none' CHECK (digest IN ('none', 'daily', 'weekly')),
+    muted_labels TEXT[] NOT NULL DEFAULT '{}' -- метки, по задачам с которыми уведомления не нужны
+);
+
+-- внешние учётные записи (OAuth2/OIDC), связанные с пользователями
+CREATE TABLE user_identities (
+    provider TEXT NOT NULL, -- поставщик: google, github, keycloak
+    subject TEXT NOT NULL, -- id пользователя у поставщика
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (provider, subject)
+);
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+
+-- сессии браузерных клиентов, хранится только SHA-256 токена
+CREATE TABLE sessions (
+    hash BYTEA PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created BIGINT NOT NULL DEFAULT extract(epoch from now()),
+    expires BIGINT NOT NULL, -- время истечения
+    revoked BIGINT NOT NULL DEFAULT 0 -- время завершения, 0 - активна
+);
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+CREATE INDEX sessions_expires_idx ON sessions (expires);
 
 -- метки задач
 CREATE TABLE labels (
     id SERIAL PRIMARY KEY,
-    name TEXT NOT NULL
+    name TEXT NOT NULL UNIQUE
+);
+
+-- эпики - крупные цели, объединяющие задачи
+CREATE TABLE epics (
+    id SERIAL PRIMARY KEY,
+    opened BIGINT NOT NULL DEFAULT extract(epoch from now()), -- время создания
+    closed BIGINT NOT NULL DEFAULT 0, -- время завершения
+    title TEXT NOT NULL, -- название эпика
+    description TEXT NOT NULL DEFAULT '' -- описание
 );
 
 -- задачи
@@ -22,16 +85,333 @@ CREATE TABLE tasks (
     id SERIAL PRIMARY KEY,
     opened BIGINT NOT NULL DEFAULT extract(epoch from now()), -- время создания задачи
     closed BIGINT DEFAULT 0, -- время выполнения задачи
+    updated BIGINT NOT NULL DEFAULT extract(epoch from now()), -- время последнего изменения
+    due BIGINT NOT NULL DEFAULT 0, -- срок выполнения, 0 - без срока
+    priority INTEGER NOT NULL DEFAULT 0, -- приоритет, больше - важнее
+    estimate INTEGER NOT NULL DEFAULT 0, -- оценка в story points
+    epic_id INTEGER REFERENCES epics(id) ON DELETE SET NULL, -- эпик
     author_id INTEGER REFERENCES users(id) DEFAULT 0, -- автор задачи
     assigned_id INTEGER REFERENCES users(id) DEFAULT 0, -- ответственный
     title TEXT, -- название задачи
-    content TEXT -- задачи
+    content TEXT, -- задачи
+    draft BOOLEAN NOT NULL DEFAULT false, -- черновик, не виден в обычных списках
+    snoozed BIGINT NOT NULL DEFAULT 0, -- отложена до этого времени, не видна в обычных списках
+    -- глобальный идентификатор для задач, созданных вне экземпляра;
+    -- умолчание определяет формат идентификаторов, выданных сервером
+    uid TEXT NOT NULL DEFAULT gen_random_uuid()::text
 );
+CREATE UNIQUE INDEX tasks_uid_idx ON tasks (uid);
+
+-- открытые задачи со сроком для выборок по сроку выполнения
+CREATE INDEX tasks_due_idx ON tasks (due) WHERE closed = 0 AND due <> 0;
+-- время последнего изменения задачи поддерживается триггером
+CREATE OR REPLACE FUNCTION tasks_touch() RETURNS trigger AS $$
+BEGIN
+    NEW.updated := extract(epoch from now());
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_touch BEFORE UPDATE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION tasks_touch();
+
+-- недавно изменённые задачи
+CREATE INDEX tasks_updated_idx ON tasks (updated);
+-- выборки по периодам создания и выполнения
+CREATE INDEX tasks_opened_idx ON tasks (opened);
+CREATE INDEX tasks_closed_idx ON tasks (closed) WHERE closed <> 0;
+-- поиск по подстроке заголовка (ILIKE)
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX tasks_title_trgm_idx ON tasks USING gin (title gin_trgm_ops);
+-- порядок разбора открытых задач (OrderTriage)
+CREATE INDEX tasks_triage_idx ON tasks (priority DESC, nullif(due, 0), opened) WHERE closed = 0;
+
+CREATE INDEX tasks_epic_id_idx ON tasks (epic_id);
+-- отложенные задачи (SnoozedTasks)
+CREATE INDEX tasks_snoozed_idx ON tasks (snoozed) WHERE snoozed <> 0;
 
 -- связь многие - ко- многим между задачами и метками
+-- хранилище удаляет связи явно до удаления задачи, каскад - страховка
+-- для удаления задач в обход хранилища
 CREATE TABLE tasks_labels (
-    task_id INTEGER REFERENCES tasks(id),
-    label_id INTEGER REFERENCES labels(id)
+    task_id INTEGER REFERENCES tasks(id) ON DELETE CASCADE,
+    label_id INTEGER REFERENCES labels(id),
+    PRIMARY KEY (task_id, label_id)
+);
+-- счётчики задач по меткам, поддерживаются триггерами
+CREATE TABLE label_stats (
+    label_id INTEGER PRIMARY KEY REFERENCES labels(id) ON DELETE CASCADE,
+    open_count INTEGER NOT NULL DEFAULT 0, -- открытые задачи
+    closed_count INTEGER NOT NULL DEFAULT 0 -- выполненные задачи
+);
+
+-- учёт добавления и снятия метки с задачи
+CREATE OR REPLACE FUNCTION label_stats_link() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO label_stats AS ls (label_id, open_count, closed_count)
+        SELECT NEW.label_id, (coalesce(t.closed, 0) = 0)::int, (coalesce(t.closed, 0) <> 0)::int
+        FROM tasks t WHERE t.id = NEW.task_id
+        ON CONFLICT (label_id) DO UPDATE
+        SET open_count = ls.open_count + EXCLUDED.open_count,
+            closed_count = ls.closed_count + EXCLUDED.closed_count;
+        RETURN NEW;
+    END IF;
+    -- при каскадном удалении задачи её строки уже нет, и связь не учитывается:
+    -- счётчики уменьшает label_stats_task_delete до удаления задачи
+    UPDATE label_stats ls
+    SET open_count = ls.open_count - (coalesce(t.closed, 0) = 0)::int,
+        closed_count = ls.closed_count - (coalesce(t.closed, 0) <> 0)::int
+    FROM tasks t
+    WHERE t.id = OLD.task_id AND ls.label_id = OLD.label_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_labels_stats AFTER INSERT OR DELETE ON tasks_labels
+    FOR EACH ROW EXECUTE FUNCTION label_stats_link();
+
+-- учёт меток удаляемой задачи, пока её строка ещё существует;
+-- связи, снятые явно до удаления задачи, уже учтены label_stats_link
+CREATE OR REPLACE FUNCTION label_stats_task_delete() RETURNS trigger AS $$
+BEGIN
+    UPDATE label_stats ls
+    SET open_count = ls.open_count - (coalesce(OLD.closed, 0) = 0)::int,
+        closed_count = ls.closed_count - (coalesce(OLD.closed, 0) <> 0)::int
+    FROM tasks_labels tl
+    WHERE tl.task_id = OLD.id AND ls.label_id = tl.label_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_label_stats_delete BEFORE DELETE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION label_stats_task_delete();
+
+-- учёт закрытия и повторного открытия задачи
+CREATE OR REPLACE FUNCTION label_stats_task() RETURNS trigger AS $$
+BEGIN
+    IF (coalesce(OLD.closed, 0) = 0) <> (coalesce(NEW.closed, 0) = 0) THEN
+        UPDATE label_stats ls
+        SET open_count = ls.open_count + CASE WHEN coalesce(NEW.closed, 0) = 0 THEN 1 ELSE -1 END,
+            closed_count = ls.closed_count + CASE WHEN coalesce(NEW.closed, 0) = 0 THEN -1 ELSE 1 END
+        FROM tasks_labels tl
+        WHERE tl.task_id = NEW.id AND ls.label_id = tl.label_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_label_stats AFTER UPDATE OF closed ON tasks
+    FOR EACH ROW EXECUTE FUNCTION label_stats_task();
+
+-- пункты чек-листа задачи - мелкие шаги, не требующие подзадач
+CREATE TABLE checklist_items (
+    id SERIAL PRIMARY KEY,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    text TEXT NOT NULL, -- текст пункта
+    done BOOLEAN NOT NULL DEFAULT false, -- выполнен
+    position INTEGER NOT NULL DEFAULT 0 -- порядок в чек-листе
 );
+CREATE INDEX checklist_items_task_id_idx ON checklist_items (task_id, position);
+
+-- задачи, отмеченные пользователями звёздочкой; отметки удаляются
+-- каскадом вместе с пользователем или задачей без отдельной очистки
+CREATE TABLE task_stars (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    created BIGINT NOT NULL DEFAULT extract(epoch from now()),
+    PRIMARY KEY (user_id, task_id)
+);
+CREATE INDEX task_stars_task_id_idx ON task_stars (task_id);
+
+-- правила автоматического назначения ответственного при создании задачи,
+-- применяется первое подходящее правило в порядке position
+CREATE TABLE assignment_rules (
+    id SERIAL PRIMARY KEY,
+    position INTEGER NOT NULL DEFAULT 0, -- порядок проверки
+    label TEXT NOT NULL DEFAULT '', -- метка задачи, '' - любая
+    epic_id INTEGER REFERENCES epics(id) ON DELETE CASCADE, -- эпик, NULL - любой
+    assignees INTEGER[] NOT NULL, -- команда
+    strategy TEXT NOT NULL DEFAULT 'round_robin', -- выбор участника команды
+    cursor BIGINT NOT NULL DEFAULT 0 -- число назначений по очереди
+);
+
+-- исходящие события об изменении задач (outbox),
+-- записываются в одной транзакции с изменением
+CREATE TABLE outbox (
+    id BIGSERIAL PRIMARY KEY,
+    created BIGINT NOT NULL DEFAULT extract(epoch from now()), -- время события
+    type TEXT NOT NULL, -- тип события
+    task_id INTEGER NOT NULL, -- задача, без внешнего ключа: задача может быть удалена
+    payload JSONB NOT NULL, -- состояние задачи
+    published BIGINT NOT NULL DEFAULT 0, -- время публикации, 0 - не опубликовано
+    xid xid8 NOT NULL DEFAULT pg_current_xact_id() -- транзакция, записавшая событие
+);
+CREATE INDEX outbox_unpublished_idx ON outbox (id) WHERE published = 0;
+-- порядок ленты событий, см. Storage.EventsAfter
+CREATE INDEX outbox_xid_idx ON outbox (xid, id);
+
+-- фоновые задания (экспорт, импорт), выполняемые обработчиками RunJobs;
+-- очередь хранится в БД, а не во внешнем брокере, и разбирается
+-- экземплярами без выбора ведущего
+CREATE TABLE jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL, -- вид задания, определяет обработчик
+    params JSONB NOT NULL DEFAULT '{}', -- параметры обработчика
+    user_id INTEGER NOT NULL DEFAULT 0, -- пользователь, поставивший задание
+    status TEXT NOT NULL DEFAULT 'queued', -- queued, running, done, failed
+    done INTEGER NOT NULL DEFAULT 0, -- выполнено единиц работы
+    total INTEGER NOT NULL DEFAULT 0, -- всего единиц работы, 0 - неизвестно
+    error TEXT NOT NULL DEFAULT '', -- ошибка выполнения
+    result BYTEA, -- результат, рассчитан на экспорт в несколько мегабайт
+    result_type TEXT NOT NULL DEFAULT '', -- тип содержимого результата
+    attempts INTEGER NOT NULL DEFAULT 0, -- число запусков
+    created BIGINT NOT NULL DEFAULT extract(epoch from now()),
+    heartbeat BIGINT NOT NULL DEFAULT 0, -- последний признак жизни обработчика
+    finished BIGINT NOT NULL DEFAULT 0
+);
+CREATE INDEX jobs_pending_idx ON jobs (id) WHERE status IN ('queued', 'running');
+
+-- позиции репликации: последнее применённое событие outbox
+-- каждого экземпляра-источника
+CREATE TABLE replica_positions (
+    source TEXT PRIMARY KEY, -- имя экземпляра-источника
+    event_id BIGINT NOT NULL DEFAULT 0, -- id последнего применённого события
+    updated BIGINT NOT NULL DEFAULT extract(epoch from now())
+);
+
+-- уведомление об изменении задачи или её меток для сброса кэшей
+-- других процессов (LISTEN tasks_changed), полезная нагрузка - id задачи;
+-- аргумент триггера - столбец с id задачи
+CREATE OR REPLACE FUNCTION tasks_notify() RETURNS trigger AS $$
+DECLARE
+    r JSONB := to_jsonb(CASE WHEN TG_OP = 'DELETE' THEN OLD ELSE NEW END);
+BEGIN
+    PERFORM pg_notify('tasks_changed', r->>TG_ARGV[0]);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_notify AFTER INSERT OR UPDATE OR DELETE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION tasks_notify('id');
+CREATE TRIGGER tasks_labels_notify AFTER INSERT OR DELETE ON tasks_labels
+    FOR EACH ROW EXECUTE FUNCTION tasks_notify('task_id');
+
+-- журнал аудита: входы и привилегированные действия,
+-- без внешних ключей, чтобы записи переживали удаление пользователей
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created BIGINT NOT NULL DEFAULT extract(epoch from now()),
+    actor_id INTEGER NOT NULL DEFAULT 0, -- пользователь, 0 - неизвестен
+    action TEXT NOT NULL, -- действие
+    target TEXT NOT NULL DEFAULT '', -- объект действия, например task:42
+    detail JSONB NOT NULL DEFAULT '{}' -- подробности
+);
+CREATE INDEX audit_log_created_idx ON audit_log (created);
+CREATE INDEX audit_log_actor_id_idx ON audit_log (actor_id);
+
+-- сводка по задачам для списков (CQRS-модель чтения): задача с именем
+-- автора и названиями меток; строки поддерживаются триггерами
+-- при изменении задач, их меток, имён пользователей и названий меток
+CREATE TABLE task_summaries (
+    id INTEGER PRIMARY KEY REFERENCES tasks(id) ON DELETE CASCADE,
+    opened BIGINT NOT NULL,
+    closed BIGINT NOT NULL DEFAULT 0,
+    updated BIGINT NOT NULL,
+    author_id INTEGER NOT NULL DEFAULT 0,
+    author_name TEXT NOT NULL DEFAULT '',
+    assigned_id INTEGER NOT NULL DEFAULT 0,
+    title TEXT NOT NULL DEFAULT '',
+    draft BOOLEAN NOT NULL DEFAULT false,
+    snoozed BIGINT NOT NULL DEFAULT 0,
+    epic_id INTEGER,
+    labels TEXT[] NOT NULL DEFAULT '{}'
+);
+
+-- пересчёт сводки задач ids; строки удалённых задач
+-- удаляются каскадом по внешнему ключу
+CREATE OR REPLACE FUNCTION task_summaries_sync(ids INTEGER[]) RETURNS void AS $$
+    INSERT INTO task_summaries AS ts (id, opened, closed, updated, author_id, author_name,
+        assigned_id, title, draft, snoozed, epic_id, labels)
+    SELECT
+        t.id,
+        t.opened,
+        coalesce(t.closed, 0),
+        t.updated,
+        coalesce(t.author_id, 0),
+        coalesce(u.name, ''),
+        coalesce(t.assigned_id, 0),
+        coalesce(t.title, ''),
+        t.draft,
+        t.snoozed,
+        t.epic_id,
+        coalesce(array_agg(l.name ORDER BY l.name) FILTER (WHERE l.id IS NOT NULL), '{}')
+    FROM tasks t
+    LEFT JOIN users u ON u.id = t.author_id
+    LEFT JOIN tasks_labels tl ON tl.task_id = t.id
+    LEFT JOIN labels l ON l.id = tl.label_id
+    WHERE t.id = ANY(ids)
+    GROUP BY t.id, u.name
+    ON CONFLICT (id) DO UPDATE
+    SET opened = EXCLUDED.opened,
+        closed = EXCLUDED.closed,
+        updated = EXCLUDED.updated,
+        author_id = EXCLUDED.author_id,
+        author_name = EXCLUDED.author_name,
+        assigned_id = EXCLUDED.assigned_id,
+        title = EXCLUDED.title,
+        draft = EXCLUDED.draft,
+        snoozed = EXCLUDED.snoozed,
+        epic_id = EXCLUDED.epic_id,
+        labels = EXCLUDED.labels;
+$$ LANGUAGE sql;
+
+-- пересчёт сводки изменённой задачи или задачи, с которой
+-- сняли или на которую повесили метку
+CREATE OR REPLACE FUNCTION task_summaries_task() RETURNS trigger AS $$
+DECLARE
+    r JSONB := to_jsonb(CASE WHEN TG_OP = 'DELETE' THEN OLD ELSE NEW END);
+BEGIN
+    PERFORM task_summaries_sync(ARRAY[(r->>TG_ARGV[0])::int]);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_summaries AFTER INSERT OR UPDATE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION task_summaries_task('id');
+CREATE TRIGGER tasks_labels_summaries AFTER INSERT OR DELETE ON tasks_labels
+    FOR EACH ROW EXECUTE FUNCTION task_summaries_task('task_id');
+
+-- имя автора в сводках его задач
+CREATE OR REPLACE FUNCTION task_summaries_user() RETURNS trigger AS $$
+BEGIN
+    UPDATE task_summaries SET author_name = NEW.name WHERE author_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_summaries AFTER UPDATE OF name ON users
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name)
+    EXECUTE FUNCTION task_summaries_user();
+
+-- названия меток в сводках задач с переименованной меткой
+CREATE OR REPLACE FUNCTION task_summaries_label() RETURNS trigger AS $$
+BEGIN
+    PERFORM task_summaries_sync(ARRAY(SELECT task_id FROM tasks_labels WHERE label_id = NEW.id));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER labels_summaries AFTER UPDATE OF name ON labels
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name)
+    EXECUTE FUNCTION task_summaries_label();
+
+-- версия схемы, проверяется Storage.CheckSchema;
+-- увеличивается при каждом изменении схемы
+CREATE TABLE schema_version (
+    version INTEGER NOT NULL
+);
+INSERT INTO schema_version (version) VALUES (4);
+
 -- наполнение БД начальными данными
 INSERT INTO users (id, name) VALUES (0, 'default');
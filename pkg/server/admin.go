@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"30-5/pkg/storage"
+)
+
+// AdminStore - операции обслуживания, например *storage.Storage.
+type AdminStore interface {
+	PermissionChecker
+	PoolStats() storage.PoolStats
+	MaintenanceHints(ctx context.Context) ([]storage.MaintenanceHint, error)
+	CleanupOrphans(ctx context.Context) (storage.CleanupStats, error)
+	SchemaStatus(ctx context.Context) (storage.SchemaStatus, error)
+}
+
+// Admin регистрирует в mux маршруты обслуживания:
+//
+//	GET  /admin/pool    - состояние пула соединений
+//	GET  /admin/hints   - рекомендации VACUUM и неиспользуемые индексы
+//	POST /admin/cleanup - удаление истёкших сессий и заданий удалённых
+//	                      пользователей, с параметром dry_run=1 - только подсчёт
+//	GET  /admin/schema  - версия схемы БД и отсутствующие объекты
+//
+// Маршруты доступны только администраторам и должны подключаться
+// за SessionAuth или APIKeyAuth.
+func Admin(mux *http.ServeMux, a AdminStore) {
+	mux.Handle("/admin/pool", requireAdmin(a, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, a.PoolStats())
+	}))
+	mux.Handle("/admin/hints", requireAdmin(a, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		hints, err := a.MaintenanceHints(r.Context())
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		writeJSON(w, hints)
+	}))
+	mux.Handle("/admin/cleanup", requireAdmin(a, http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if r.URL.Query().Get("dry_run") == "1" {
+			ctx = storage.WithDryRun(ctx)
+		}
+		stats, err := a.CleanupOrphans(ctx)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		writeJSON(w, stats)
+	}))
+	mux.Handle("/admin/schema", requireAdmin(a, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		st, err := a.SchemaStatus(r.Context())
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		writeJSON(w, st)
+	}))
+}
+
+// requireAdmin пропускает к h только запросы методом method
+// от пользователей с правом обслуживания.
+func requireAdmin(p PermissionChecker, method string, h http.HandlerFunc) http.Handler {
+	return requireAction(p, storage.ActionMaintain, method, h)
+}
+
+// PermissionChecker - проверка прав пользователя, например *storage.Storage.
+type PermissionChecker interface {
+	CheckPermission(ctx context.Context, userID int, action storage.Action, taskID int) error
+}
+
+// requireAction пропускает к h только запросы методом method
+// (любым, если method пуст) от пользователей с правом на действие action.
+func requireAction(p PermissionChecker, action storage.Action, method string, h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if method != "" && r.Method != method {
+			writeCode(w, r, CodeMethodNotAllowed)
+			return
+		}
+		uid, ok := UserID(r.Context())
+		if !ok {
+			writeCode(w, r, CodeUnauthorized)
+			return
+		}
+		if err := p.CheckPermission(r.Context(), uid, action, 0); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		h(w, r)
+	})
+}
+
+// writeJSON отвечает значением v в формате JSON.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"30-5/pkg/storage"
+)
+
+// IncidentStore - задачи инцидентов, например *storage.Storage.
+type IncidentStore interface {
+	TaskByUID(ctx context.Context, uid string) (storage.Task, error)
+	NewTaskWithLabels(ctx context.Context, t storage.Task, labels []string) (storage.Task, error)
+	UpdateTask(ctx context.Context, t storage.Task) (storage.Task, error)
+}
+
+// Уведомление Alertmanager (webhook_config, версия 4).
+type alertGroup struct {
+	Status            string            `json:"status"` // firing или resolved
+	GroupKey          string            `json:"groupKey"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []struct {
+		Status       string            `json:"status"`
+		Labels       map[string]string `json:"labels"`
+		Annotations  map[string]string `json:"annotations"`
+		StartsAt     time.Time         `json:"startsAt"`
+		GeneratorURL string            `json:"generatorURL"`
+	} `json:"alerts"`
+}
+
+// Настройка приёмника Alertmanager.
+type AlertmanagerConfig struct {
+	// Token - секрет, передаваемый Alertmanager в заголовке
+	// "Authorization: Bearer" (http_config.authorization).
+	// Пустой секрет отвергает все запросы.
+	Token      string
+	AuthorID   int      // автор задач инцидентов
+	AssignedID int      // ответственный, 0 - по правилам назначения
+	Labels     []string // метки задач, к ним добавляется severity группы
+	// Priorities - приоритет задачи по метке severity группы.
+	Priorities map[string]int
+}
+
+// Alertmanager возвращает обработчик уведомлений Prometheus Alertmanager.
+// На каждую группу оповещений, а не на каждое оповещение, заводится
+// задача инцидента - так Alertmanager группирует и повторяет
+// уведомления. Задача связана с группой через UID, без отдельной
+// таблицы: повторное уведомление о срабатывании обновляет
+// описание задачи (и открывает её снова, если она была закрыта),
+// а уведомление о разрешении закрывает задачу.
+func Alertmanager(s IncidentStore, cfg AlertmanagerConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeCode(w, r, CodeMethodNotAllowed)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if cfg.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) != 1 {
+			writeCode(w, r, CodeUnauthorized)
+			return
+		}
+		var g alertGroup
+		if err := json.NewDecoder(r.Body).Decode(&g); err != nil || g.GroupKey == "" {
+			writeCode(w, r, CodeBadRequest)
+			return
+		}
+		t, err := applyAlertGroup(r.Context(), s, cfg, g)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		writeJSON(w, t)
+	})
+}
+
+// applyAlertGroup создаёт, обновляет или закрывает задачу группы g.
+func applyAlertGroup(ctx context.Context, s IncidentStore, cfg AlertmanagerConfig, g alertGroup) (storage.Task, error) {
+	sum := sha256.Sum256([]byte(g.GroupKey))
+	uid := "alertmanager:" + hex.EncodeToString(sum[:16])
+	resolved := g.Status == "resolved"
+
+	t, err := s.TaskByUID(ctx, uid)
+	if errors.Is(err, storage.ErrTaskNotFound) {
+		if resolved {
+			// разрешилась группа, задача которой не заводилась
+			return storage.Task{}, nil
+		}
+		severity := g.CommonLabels["severity"]
+		labels := append([]string{}, cfg.Labels...)
+		if severity != "" {
+			labels = append(labels, severity)
+		}
+		t, err = s.NewTaskWithLabels(ctx, storage.Task{
+			UID:        uid,
+			Title:      alertTitle(g),
+			Content:    alertContent(g),
+			Priority:   cfg.Priorities[severity],
+			AuthorID:   cfg.AuthorID,
+			AssignedID: cfg.AssignedID,
+		}, labels)
+		if !errors.Is(err, storage.ErrDuplicateUID) {
+			return t, err
+		}
+		// задачу одновременно завёл параллельный запрос
+		t, err = s.TaskByUID(ctx, uid)
+	}
+	if err != nil {
+		return storage.Task{}, err
+	}
+	switch {
+	case resolved && t.Closed == 0:
+		t.Closed = time.Now().Unix()
+	case resolved:
+		return t, nil
+	default:
+		t.Closed = 0
+		t.Content = alertContent(g)
+	}
+	return s.UpdateTask(ctx, t)
+}
+
+// alertTitle возвращает заголовок задачи группы оповещений.
+func alertTitle(g alertGroup) string {
+	if s := g.CommonAnnotations["summary"]; s != "" {
+		return s
+	}
+	if name := g.CommonLabels["alertname"]; name != "" {
+		return "Оповещение " + name
+	}
+	return "Оповещение " + sortedLabels(g.GroupLabels)
+}
+
+// alertContent возвращает описание задачи со списком оповещений группы.
+func alertContent(g alertGroup) string {
+	var sb strings.Builder
+	if d := g.CommonAnnotations["description"]; d != "" {
+		sb.WriteString(d + "\n\n")
+	}
+	for _, a := range g.Alerts {
+		fmt.Fprintf(&sb, "- [%s] %s", a.Status, sortedLabels(a.Labels))
+		if s := a.Annotations["summary"]; s != "" {
+			sb.WriteString(": " + s)
+		}
+		if !a.StartsAt.IsZero() {
+			sb.WriteString(" (с " + a.StartsAt.UTC().Format("2006-01-02 15:04 MST") + ")")
+		}
+		if a.GeneratorURL != "" {
+			sb.WriteString(" " + a.GeneratorURL)
+		}
+		sb.WriteString("\n")
+	}
+	if g.ExternalURL != "" {
+		sb.WriteString("\nAlertmanager: " + g.ExternalURL + "\n")
+	}
+	return sb.String()
+}
+
+// sortedLabels форматирует метки оповещения в порядке имён.
+func sortedLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + labels[name]
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
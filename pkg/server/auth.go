@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"30-5/pkg/storage"
+)
+
+// Authenticator проверяет ключ API, например *storage.Storage.
+type Authenticator interface {
+	AuthenticateAPIKey(ctx context.Context, secret string) (storage.APIKey, error)
+}
+
+// ключ контекста для ключа API запроса
+type apiKeyKey struct{}
+
+// APIKeyAuth пропускает к next только запросы с действующим ключом API
+// в заголовке "Authorization: Bearer <ключ>" или "X-API-Key".
+// Ключ доступен обработчикам через APIKeyFrom, а действия
+// выполняются от имени его владельца (см. UserID).
+func APIKeyAuth(a Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := r.Header.Get("X-API-Key")
+		if v := r.Header.Get("Authorization"); strings.HasPrefix(v, "Bearer ") {
+			secret = strings.TrimPrefix(v, "Bearer ")
+		}
+		if secret == "" {
+			writeCode(w, r, CodeUnauthorized)
+			return
+		}
+		key, err := a.AuthenticateAPIKey(r.Context(), secret)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), apiKeyKey{}, key)
+		ctx = context.WithValue(ctx, userKey{}, key.UserID)
+		ctx = storage.WithActor(ctx, key.UserID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireScope пропускает к next только запросы, ключ API которых
+// имеет область scope. Используется внутри APIKeyAuth.
+func RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := APIKeyFrom(r.Context())
+		if !ok || !key.HasScope(scope) {
+			writeCode(w, r, CodeInsufficientScope)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// APIKeyFrom возвращает ключ API, которым аутентифицирован запрос.
+func APIKeyFrom(ctx context.Context) (storage.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyKey{}).(storage.APIKey)
+	return key, ok
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+
+	"30-5/pkg/cache"
+	"30-5/pkg/storage"
+)
+
+// DiagnosticsSource - сведения о хранилище для диагностики,
+// например *storage.Storage.
+type DiagnosticsSource interface {
+	PermissionChecker
+	PoolStats() storage.PoolStats
+	OutboxBacklog(ctx context.Context) (int64, error)
+}
+
+// CacheStats - кэш со счётчиками обращений, например *cache.LRU или *cache.Redis.
+type CacheStats interface {
+	Stats() cache.Stats
+}
+
+// Diagnostics регистрирует в mux маршруты диагностики:
+//
+//	GET /debug/pprof/            - список профилей
+//	GET /debug/pprof/<профиль>   - профиль runtime/pprof (heap, goroutine,
+//	                               allocs, block, mutex, threadcreate),
+//	                               ?debug=N - текстовый формат
+//	GET /debug/pprof/profile     - профиль процессора за ?seconds=N
+//	GET /debug/pprof/trace       - трассировка выполнения за ?seconds=N
+//	GET /admin/diagnostics       - пул соединений, попадания в кэши caches
+//	                               по именам, очередь outbox и состояние среды
+//
+// Кэши передаются по именам, чтобы развёртывание показывало те слои,
+// которые подключило.
+// Маршруты доступны только администраторам и должны подключаться
+// за SessionAuth или APIKeyAuth. Пакет net/http/pprof не используется,
+// так как при импорте он регистрирует свои обработчики без проверки
+// прав в http.DefaultServeMux.
+func Diagnostics(mux *http.ServeMux, d DiagnosticsSource, caches map[string]CacheStats) {
+	mux.Handle("/debug/pprof/", requireAction(d, storage.ActionMaintain, http.MethodGet, profileHandler))
+	mux.Handle("/debug/pprof/profile", requireAction(d, storage.ActionMaintain, http.MethodGet, cpuProfileHandler))
+	mux.Handle("/debug/pprof/trace", requireAction(d, storage.ActionMaintain, http.MethodGet, traceHandler))
+	mux.Handle("/admin/diagnostics", requireAdmin(d, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		backlog, err := d.OutboxBacklog(r.Context())
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		type cacheInfo struct {
+			cache.Stats
+			HitRate float64 `json:"hit_rate"`
+		}
+		info := struct {
+			Pool          storage.PoolStats    `json:"pool"`
+			OutboxBacklog int64                `json:"outbox_backlog"`
+			Caches        map[string]cacheInfo `json:"caches"`
+			Goroutines    int                  `json:"goroutines"`
+			HeapAlloc     uint64               `json:"heap_alloc"`
+			NumGC         uint32               `json:"num_gc"`
+		}{
+			Pool:          d.PoolStats(),
+			OutboxBacklog: backlog,
+			Caches:        make(map[string]cacheInfo, len(caches)),
+			Goroutines:    runtime.NumGoroutine(),
+		}
+		for name, c := range caches {
+			st := c.Stats()
+			info.Caches[name] = cacheInfo{st, st.HitRate()}
+		}
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		info.HeapAlloc, info.NumGC = ms.HeapAlloc, ms.NumGC
+		writeJSON(w, info)
+	}))
+}
+
+// Наибольшая длительность профиля процессора и трассировки.
+const maxProfileDuration = 60 * time.Second
+
+// profileHandler отдаёт список профилей или профиль по имени.
+func profileHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/debug/pprof/")
+	if name == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, p := range pprof.Profiles() {
+			fmt.Fprintf(w, "%s %d\n", p.Name(), p.Count())
+		}
+		return
+	}
+	p := pprof.Lookup(name)
+	if p == nil {
+		writeCode(w, r, CodeNotFound)
+		return
+	}
+	debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+	if debug > 0 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	}
+	if name == "heap" && r.URL.Query().Get("gc") != "" {
+		runtime.GC()
+	}
+	p.WriteTo(w, debug)
+}
+
+// cpuProfileHandler снимает профиль процессора.
+func cpuProfileHandler(w http.ResponseWriter, r *http.Request) {
+	d := profileDuration(r)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+	if err := pprof.StartCPUProfile(w); err != nil {
+		// профиль уже снимается другим запросом
+		writeCode(w, r, CodeBusy)
+		return
+	}
+	sleep(r.Context(), d)
+	pprof.StopCPUProfile()
+}
+
+// traceHandler снимает трассировку выполнения.
+func traceHandler(w http.ResponseWriter, r *http.Request) {
+	d := profileDuration(r)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+	if err := trace.Start(w); err != nil {
+		writeCode(w, r, CodeBusy)
+		return
+	}
+	sleep(r.Context(), d)
+	trace.Stop()
+}
+
+// profileDuration возвращает длительность из параметра seconds,
+// по умолчанию 30 секунд, не более maxProfileDuration.
+func profileDuration(r *http.Request) time.Duration {
+	d := 30 * time.Second
+	if sec, err := strconv.Atoi(r.URL.Query().Get("seconds")); err == nil && sec > 0 {
+		d = time.Duration(sec) * time.Second
+	}
+	if d > maxProfileDuration {
+		d = maxProfileDuration
+	}
+	return d
+}
+
+// sleep ждёт d или отмены ctx.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"30-5/pkg/middleware"
+	"30-5/pkg/storage"
+)
+
+// Code - стабильный машиночитаемый код ошибки API.
+// Коды не переводятся и не меняются между версиями.
+type Code string
+
+const (
+	CodeBadRequest         Code = "bad_request"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeInvalidAPIKey      Code = "invalid_api_key"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeForbidden          Code = "forbidden"
+	CodeInsufficientScope  Code = "insufficient_scope"
+	CodeNotFound           Code = "not_found"
+	CodeMethodNotAllowed   Code = "method_not_allowed"
+	CodeInvalidTask        Code = "invalid_task"
+	CodeQuotaExceeded      Code = "quota_exceeded"
+	CodeDuplicateUID       Code = "duplicate_uid"
+	CodeBusy               Code = "busy"
+	CodeConflict           Code = "conflict"
+	CodeBatchAborted       Code = "batch_aborted"
+	CodeReadOnly           Code = "read_only"
+	CodeUpstream           Code = "upstream_error"
+	CodeUnavailable        Code = "unavailable"
+	CodeInternal           Code = "internal"
+)
+
+// язык сообщений по умолчанию
+const defaultLocale = "en"
+
+// Описание кода ошибки: статус HTTP и сообщения по языкам.
+type catalogEntry struct {
+	status   int
+	messages map[string]string
+}
+
+// каталог сообщений об ошибках
+var catalog = map[Code]catalogEntry{
+	CodeBadRequest: {http.StatusBadRequest, map[string]string{
+		"en": "Invalid request.",
+		"ru": "Некорректный запрос.",
+	}},
+	CodeUnauthorized: {http.StatusUnauthorized, map[string]string{
+		"en": "Authentication required.",
+		"ru": "Требуется вход в систему.",
+	}},
+	CodeInvalidAPIKey: {http.StatusUnauthorized, map[string]string{
+		"en": "The API key is invalid or revoked.",
+		"ru": "Ключ API недействителен или отозван.",
+	}},
+	CodeInvalidCredentials: {http.StatusUnauthorized, map[string]string{
+		"en": "Invalid email or password.",
+		"ru": "Неверный адрес или пароль.",
+	}},
+	CodeForbidden: {http.StatusForbidden, map[string]string{
+		"en": "You do not have permission to perform this action.",
+		"ru": "Недостаточно прав для этого действия.",
+	}},
+	CodeInsufficientScope: {http.StatusForbidden, map[string]string{
+		"en": "The API key does not allow this action.",
+		"ru": "Ключ API не разрешает это действие.",
+	}},
+	CodeNotFound: {http.StatusNotFound, map[string]string{
+		"en": "Not found.",
+		"ru": "Не найдено.",
+	}},
+	CodeMethodNotAllowed: {http.StatusMethodNotAllowed, map[string]string{
+		"en": "Method not allowed.",
+		"ru": "Метод не поддерживается.",
+	}},
+	CodeInvalidTask: {http.StatusUnprocessableEntity, map[string]string{
+		"en": "Task title is required.",
+		"ru": "Не указан заголовок задачи.",
+	}},
+	CodeQuotaExceeded: {http.StatusConflict, map[string]string{
+		"en": "The author has too many open tasks.",
+		"ru": "У автора слишком много открытых задач.",
+	}},
+	CodeDuplicateUID: {http.StatusConflict, map[string]string{
+		"en": "A task with this UID already exists.",
+		"ru": "Задача с таким UID уже существует.",
+	}},
+	CodeBusy: {http.StatusConflict, map[string]string{
+		"en": "The operation is already in progress.",
+		"ru": "Операция уже выполняется.",
+	}},
+	CodeConflict: {http.StatusConflict, map[string]string{
+		"en": "The data was changed by a concurrent request.",
+		"ru": "Данные изменены параллельным запросом.",
+	}},
+	CodeBatchAborted: {http.StatusConflict, map[string]string{
+		"en": "Some items failed; no changes were saved.",
+		"ru": "Часть элементов не обработана; изменения не сохранены.",
+	}},
+	CodeReadOnly: {http.StatusServiceUnavailable, map[string]string{
+		"en": "The service is in read-only mode.",
+		"ru": "Сервис работает только на чтение.",
+	}},
+	CodeUpstream: {http.StatusBadGateway, map[string]string{
+		"en": "The identity provider request failed.",
+		"ru": "Ошибка запроса к поставщику учётных записей.",
+	}},
+	CodeUnavailable: {http.StatusServiceUnavailable, map[string]string{
+		"en": "The service is temporarily unavailable.",
+		"ru": "Сервис временно недоступен.",
+	}},
+	CodeInternal: {http.StatusInternalServerError, map[string]string{
+		"en": "Internal error.",
+		"ru": "Внутренняя ошибка.",
+	}},
+}
+
+// коды ошибок хранилища и предохранителя; прочие ошибки -
+// CodeInternal, чтобы непредвиденная ошибка не выдавалась
+// за временную недоступность
+var storageCodes = []struct {
+	err  error
+	code Code
+}{
+	{storage.ErrTaskNotFound, CodeNotFound},
+	{storage.ErrEpicNotFound, CodeNotFound},
+	{storage.ErrUserNotFound, CodeNotFound},
+	{storage.ErrAPIKeyNotFound, CodeNotFound},
+	{storage.ErrRuleNotFound, CodeNotFound},
+	{storage.ErrJobNotFound, CodeNotFound},
+	{storage.ErrChecklistItemNotFound, CodeNotFound},
+	{storage.ErrEventNotFound, CodeNotFound},
+	{storage.ErrInvalidRule, CodeBadRequest},
+	{storage.ErrInvalidPrefs, CodeBadRequest},
+	{storage.ErrUnknownRole, CodeBadRequest},
+	{storage.ErrPasswordTooLong, CodeBadRequest},
+	{storage.ErrEmptyPassword, CodeBadRequest},
+	{storage.ErrTooManyRows, CodeBadRequest},
+	{storage.ErrSelfMerge, CodeBadRequest},
+	{storage.ErrInvalidTask, CodeInvalidTask},
+	{storage.ErrQuotaExceeded, CodeQuotaExceeded},
+	{storage.ErrDuplicateUID, CodeDuplicateUID},
+	{storage.ErrReplicaPosition, CodeConflict},
+	{storage.ErrUserConflict, CodeConflict},
+	{storage.ErrBatchAborted, CodeBatchAborted},
+	{storage.ErrReadOnly, CodeReadOnly},
+	{storage.ErrForbidden, CodeForbidden},
+	{storage.ErrInvalidCredentials, CodeInvalidCredentials},
+	{storage.ErrInvalidAPIKey, CodeInvalidAPIKey},
+	{storage.ErrInvalidSession, CodeUnauthorized},
+	{storage.ErrSchemaMismatch, CodeInternal},
+	{middleware.ErrUnavailable, CodeUnavailable},
+}
+
+// ErrorCode возвращает код ошибки API для ошибки хранилища.
+func ErrorCode(err error) Code {
+	for _, c := range storageCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return CodeInternal
+}
+
+// WriteError отвечает на запрос r ошибкой err в формате JSON:
+// {"code": "...", "message": "..."}, где сообщение переведено на язык
+// из заголовка Accept-Language.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	writeCode(w, r, ErrorCode(err))
+}
+
+// writeCode отвечает на запрос r ошибкой с кодом code.
+func writeCode(w http.ResponseWriter, r *http.Request, code Code) {
+	e, ok := catalog[code]
+	if !ok {
+		code, e = CodeInternal, catalog[CodeInternal]
+	}
+	lang := locale(r.Header.Get("Accept-Language"), e.messages)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(struct {
+		Code    Code   `json:"code"`
+		Message string `json:"message"`
+	}{code, e.messages[lang]})
+}
+
+// locale выбирает из messages язык с наибольшим весом в заголовке
+// Accept-Language, например "ru-RU,ru;q=0.9,en;q=0.8".
+func locale(header string, messages map[string]string) string {
+	type pref struct {
+		lang string
+		q    float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		p := pref{lang: strings.ToLower(strings.TrimSpace(fields[0])), q: 1}
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if q, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					p.q = q
+				}
+			}
+		}
+		// региональный вариант заменяется основным языком
+		if i := strings.IndexByte(p.lang, '-'); i > 0 {
+			p.lang = p.lang[:i]
+		}
+		if p.q > 0 {
+			prefs = append(prefs, p)
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	for _, p := range prefs {
+		if _, ok := messages[p.lang]; ok {
+			return p.lang
+		}
+	}
+	return defaultLocale
+}
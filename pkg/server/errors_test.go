@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"30-5/pkg/middleware"
+	"30-5/pkg/storage"
+)
+
+func TestLocale(t *testing.T) {
+	messages := map[string]string{"en": "", "ru": ""}
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"ru", "ru"},
+		{"RU", "ru"},
+		{"ru-RU", "ru"},
+		{"ru-RU,ru;q=0.9,en;q=0.8", "ru"},
+		{"en;q=0.5, ru;q=0.8", "ru"},
+		{"de, ru;q=0.3", "ru"},
+		{"de, fr", "en"},
+		{"ru;q=0", "en"},
+		{"ru;q=abc", "ru"},
+		{"en-GB, ru", "en"},
+		{" ru ; q=0.9 ", "ru"},
+	}
+	for _, tt := range tests {
+		if got := locale(tt.header, messages); got != tt.want {
+			t.Errorf("locale(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Code
+	}{
+		{storage.ErrTaskNotFound, CodeNotFound},
+		{fmt.Errorf("load: %w", storage.ErrTaskNotFound), CodeNotFound},
+		{storage.ErrInvalidTask, CodeInvalidTask},
+		{storage.ErrQuotaExceeded, CodeQuotaExceeded},
+		{storage.ErrDuplicateUID, CodeDuplicateUID},
+		{storage.ErrReadOnly, CodeReadOnly},
+		{storage.ErrForbidden, CodeForbidden},
+		{storage.ErrInvalidAPIKey, CodeInvalidAPIKey},
+		{storage.ErrInvalidSession, CodeUnauthorized},
+		{storage.ErrPasswordTooLong, CodeBadRequest},
+		{storage.ErrEmptyPassword, CodeBadRequest},
+		{storage.ErrTooManyRows, CodeBadRequest},
+		{storage.ErrSelfMerge, CodeBadRequest},
+		{fmt.Errorf("import: %w", storage.ErrBatchAborted), CodeBatchAborted},
+		{storage.ErrSchemaMismatch, CodeInternal},
+		{middleware.ErrUnavailable, CodeUnavailable},
+		{errors.New("connection refused"), CodeInternal},
+	}
+	for _, tt := range tests {
+		if got := ErrorCode(tt.err); got != tt.want {
+			t.Errorf("ErrorCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogComplete(t *testing.T) {
+	// у каждого кода есть статус и сообщение на языке по умолчанию
+	for code, e := range catalog {
+		if e.status < 400 || e.messages[defaultLocale] == "" {
+			t.Errorf("catalog entry %q = %+v", code, e)
+		}
+	}
+	for _, c := range storageCodes {
+		if _, ok := catalog[c.code]; !ok {
+			t.Errorf("code %q for %v is missing from catalog", c.code, c.err)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "ru-RU")
+	WriteError(rec, req, storage.ErrTaskNotFound)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Language"); got != "ru" {
+		t.Errorf("Content-Language = %q, want ru", got)
+	}
+	var body struct {
+		Code    Code   `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != CodeNotFound || body.Message != catalog[CodeNotFound].messages["ru"] {
+		t.Errorf("body = %+v", body)
+	}
+}
@@ -0,0 +1,34 @@
+// Пакет server содержит HTTP-обработчики служебных маршрутов
+// и запуск HTTP-сервера поверх хранилища задач.
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Pinger проверяет доступность хранилища, например *storage.Storage.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Health регистрирует в mux обработчики проверок Kubernetes:
+// /healthz отвечает 200, пока процесс работает, а /readyz - только
+// если хранилище p отвечает в течение timeout, иначе 503. Проверка
+// выполняется часто, поэтому /readyz только проверяет соединение;
+// схема БД проверяется один раз при запуске (storage.WithSchemaCheck).
+func Health(mux *http.ServeMux, p Pinger, timeout time.Duration) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		if err := p.Ping(ctx); err != nil {
+			writeCode(w, r, CodeUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"30-5/pkg/storage"
+)
+
+// JobStore - очередь фоновых заданий, например *storage.Storage.
+type JobStore interface {
+	EnqueueJob(ctx context.Context, kind string, params any) (storage.Job, error)
+	JobByID(ctx context.Context, id int64) (storage.Job, error)
+	JobResult(ctx context.Context, id int64) (storage.JobResult, error)
+}
+
+// Jobs регистрирует в mux маршруты фоновых заданий видов kinds:
+//
+//	POST /jobs?kind=...      - постановка задания с параметрами из тела JSON,
+//	                           ответ 202 с заданием и заголовком Location
+//	GET  /jobs/{id}          - состояние и ход выполнения задания
+//	GET  /jobs/{id}/result   - результат выполненного задания
+//
+// Задание доступно только поставившему его пользователю.
+// Маршруты должны подключаться за SessionAuth или APIKeyAuth.
+func Jobs(mux *http.ServeMux, js JobStore, kinds ...string) {
+	mux.HandleFunc("/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeCode(w, r, CodeMethodNotAllowed)
+			return
+		}
+		if _, ok := UserID(r.Context()); !ok {
+			writeCode(w, r, CodeUnauthorized)
+			return
+		}
+		kind := r.URL.Query().Get("kind")
+		if !contains(kinds, kind) {
+			writeCode(w, r, CodeBadRequest)
+			return
+		}
+		var params json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+			writeCode(w, r, CodeBadRequest)
+			return
+		}
+		job, err := js.EnqueueJob(r.Context(), kind, params)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		w.Header().Set("Location", "/jobs/"+strconv.FormatInt(job.ID, 10))
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	})
+	mux.HandleFunc("/jobs/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeCode(w, r, CodeMethodNotAllowed)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/jobs/")
+		result := strings.HasSuffix(path, "/result")
+		id, err := strconv.ParseInt(strings.TrimSuffix(path, "/result"), 10, 64)
+		if err != nil {
+			writeCode(w, r, CodeNotFound)
+			return
+		}
+		uid, ok := UserID(r.Context())
+		if !ok {
+			writeCode(w, r, CodeUnauthorized)
+			return
+		}
+		job, err := js.JobByID(r.Context(), id)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if job.UserID != uid {
+			writeCode(w, r, CodeNotFound)
+			return
+		}
+		if !result {
+			writeJSON(w, job)
+			return
+		}
+		res, err := js.JobResult(r.Context(), id)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if res.ContentType != "" {
+			w.Header().Set("Content-Type", res.ContentType)
+		}
+		w.Write(res.Data)
+	})
+}
+
+// contains сообщает, есть ли s в списке list.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"30-5/pkg/storage"
+)
+
+// OAuthProvider - настройки входа через поставщика OAuth2/OIDC
+// по коду авторизации. Профиль пользователя запрашивается у UserInfoURL
+// с полученным токеном доступа.
+type OAuthProvider struct {
+	Name         string // имя поставщика, под ним хранятся учётные записи
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // адрес обработчика OAuthCallback
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	Scopes       []string
+
+	// Поля ответа UserInfoURL; по умолчанию "sub", "name", "email"
+	// и "email_verified". Адрес почты берётся из профиля, только если
+	// поле VerifiedField равно true: адрес служит логином для входа
+	// по паролю, и неподтверждённый адрес позволил бы занять чужой.
+	SubjectField  string
+	NameField     string
+	EmailField    string
+	VerifiedField string
+}
+
+// Google возвращает настройки входа через Google.
+func Google(clientID, clientSecret, redirectURL string) OAuthProvider {
+	return OAuthProvider{
+		Name:         "google",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:     "https://oauth2.googleapis.com/token",
+		UserInfoURL:  "https://openidconnect.googleapis.com/v1/userinfo",
+		Scopes:       []string{"openid", "profile", "email"},
+	}
+}
+
+// GitHub возвращает настройки входа через GitHub (OAuth2 без OIDC).
+// Профиль GitHub не сообщает, подтверждён ли адрес почты,
+// поэтому адрес пользователю не сохраняется.
+func GitHub(clientID, clientSecret, redirectURL string) OAuthProvider {
+	return OAuthProvider{
+		Name:         "github",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		AuthURL:      "https://github.com/login/oauth/authorize",
+		TokenURL:     "https://github.com/login/oauth/access_token",
+		UserInfoURL:  "https://api.github.com/user",
+		Scopes:       []string{"read:user", "user:email"},
+		SubjectField: "id",
+		NameField:    "login",
+	}
+}
+
+// Keycloak возвращает настройки входа через область realm сервера
+// Keycloak с адресом baseURL.
+func Keycloak(baseURL, realm, clientID, clientSecret, redirectURL string) OAuthProvider {
+	base := strings.TrimRight(baseURL, "/") + "/realms/" + url.PathEscape(realm) + "/protocol/openid-connect"
+	return OAuthProvider{
+		Name:         "keycloak",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		AuthURL:      base + "/auth",
+		TokenURL:     base + "/token",
+		UserInfoURL:  base + "/userinfo",
+		Scopes:       []string{"openid", "profile", "email"},
+	}
+}
+
+// IdentityStore сопоставляет внешние учётные записи пользователям,
+// например *storage.Storage.
+type IdentityStore interface {
+	UserByIdentity(ctx context.Context, provider, subject string, profile storage.User) (storage.User, error)
+}
+
+// имя cookie с параметром state запроса авторизации
+const oauthStateCookie = "oauth_state"
+
+// OAuthLogin возвращает обработчик, перенаправляющий пользователя
+// на страницу входа поставщика p.
+func OAuthLogin(p OAuthProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			writeCode(w, r, CodeInternal)
+			return
+		}
+		state := base64.RawURLEncoding.EncodeToString(b)
+		http.SetCookie(w, &http.Cookie{
+			Name:     oauthStateCookie,
+			Value:    state,
+			Path:     "/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		q := url.Values{
+			"response_type": {"code"},
+			"client_id":     {p.ClientID},
+			"redirect_uri":  {p.RedirectURL},
+			"scope":         {strings.Join(p.Scopes, " ")},
+			"state":         {state},
+		}
+		http.Redirect(w, r, p.AuthURL+"?"+q.Encode(), http.StatusFound)
+	})
+}
+
+// OAuthCallback возвращает обработчик возврата от поставщика p:
+// обменивает код на токен, запрашивает профиль, находит или создаёт
+// пользователя и открывает сессию сроком ttl.
+// Для запросов к поставщику используется client.
+func OAuthCallback(p OAuthProvider, client *http.Client, ids IdentityStore, store SessionStore, ttl time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(oauthStateCookie)
+		if err != nil || c.Value == "" || c.Value != r.URL.Query().Get("state") {
+			writeCode(w, r, CodeBadRequest)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/", MaxAge: -1})
+		code := r.URL.Query().Get("code")
+		if code == "" {
+			writeCode(w, r, CodeUnauthorized)
+			return
+		}
+		token, err := p.exchange(r.Context(), client, code)
+		if err != nil {
+			writeCode(w, r, CodeUpstream)
+			return
+		}
+		subject, profile, err := p.userInfo(r.Context(), client, token)
+		if err != nil {
+			writeCode(w, r, CodeUpstream)
+			return
+		}
+		u, err := ids.UserByIdentity(r.Context(), p.Name, subject, profile)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if err = StartSession(w, r, store, u.ID, ttl); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+}
+
+// exchange обменивает код авторизации на токен доступа.
+func (p OAuthProvider) exchange(ctx context.Context, client *http.Client, code string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err = doJSON(client, req, &resp); err != nil {
+		return "", err
+	}
+	if resp.AccessToken == "" {
+		return "", fmt.Errorf("token exchange failed: %s", resp.Error)
+	}
+	return resp.AccessToken, nil
+}
+
+// userInfo запрашивает профиль пользователя с токеном доступа.
+func (p OAuthProvider) userInfo(ctx context.Context, client *http.Client, token string) (string, storage.User, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.UserInfoURL, nil)
+	if err != nil {
+		return "", storage.User{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	var info map[string]any
+	if err = doJSON(client, req, &info); err != nil {
+		return "", storage.User{}, err
+	}
+	field := func(name, def string) string {
+		if name == "" {
+			name = def
+		}
+		switch v := info[name].(type) {
+		case string:
+			return v
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		}
+		return ""
+	}
+	subject := field(p.SubjectField, "sub")
+	if subject == "" {
+		return "", storage.User{}, fmt.Errorf("no subject in user info")
+	}
+	profile := storage.User{Name: field(p.NameField, "name")}
+	verified := p.VerifiedField
+	if verified == "" {
+		verified = "email_verified"
+	}
+	if v, _ := info[verified].(bool); v {
+		profile.Email = field(p.EmailField, "email")
+	}
+	return subject, profile, nil
+}
+
+// doJSON выполняет запрос и декодирует JSON-ответ в v.
+func doJSON(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPI возвращает обработчик GET-запроса описания API задач и меток
+// в формате OpenAPI 3. Пути в описании соответствуют подключению:
+//
+//	/tasks                    - Tasks
+//	/tasks/search             - Search
+//	/tasks/events             - Events
+//	/assignment-rules[/{id}]  - AssignmentRules
+//
+// По этому описанию написан клиент из пакета client.
+func OpenAPI() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeCode(w, r, CodeMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(openAPISpec)
+	})
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	OpenAPI().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.OpenAPI == "" {
+		t.Fatal("openapi version is missing")
+	}
+	// операции, которые обслуживают обработчики пакета
+	want := map[string]string{
+		"listTasks":            "GET /tasks",
+		"searchTasks":          "GET /tasks/search",
+		"taskEvents":           "GET /tasks/events",
+		"listAssignmentRules":  "GET /assignment-rules",
+		"createAssignmentRule": "POST /assignment-rules",
+		"deleteAssignmentRule": "DELETE /assignment-rules/{id}",
+	}
+	got := make(map[string]string)
+	for path, ops := range spec.Paths {
+		for method, raw := range ops {
+			var op struct {
+				OperationID string `json:"operationId"`
+			}
+			if err := json.Unmarshal(raw, &op); err != nil {
+				t.Fatal(err)
+			}
+			got[op.OperationID] = map[string]string{
+				"get": "GET", "post": "POST", "delete": "DELETE",
+			}[method] + " " + path
+		}
+	}
+	for id, route := range want {
+		if got[id] != route {
+			t.Errorf("operation %s = %q, want %q", id, got[id], route)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("operations = %v", got)
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"30-5/pkg/storage"
+)
+
+// Replica - приёмник репликации задач, например *storage.Storage.
+type Replica interface {
+	PermissionChecker
+	ReplicaPosition(ctx context.Context, source string) (int64, error)
+	ApplyEvents(ctx context.Context, source string, after int64, events []storage.Event) (int64, error)
+}
+
+// Событие outbox в запросе репликации.
+type ReplicaEvent struct {
+	ID      int64           `json:"id"`
+	Created int64           `json:"created"`
+	Type    string          `json:"type"`
+	TaskID  int             `json:"task_id"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Позиция репликации в ответе приёмника.
+type replicaPosition struct {
+	Position int64 `json:"position"`
+}
+
+// Replication возвращает обработчик приёма репликации задач
+// от экземпляра, имя которого передаётся в параметре source:
+//
+//	GET  - позиция репликации {"position": ...}
+//	POST - применение массива ReplicaEvent, следующих за позицией
+//	       из параметра after, ответ - новая позиция; если позиция
+//	       приёмника другая, ответ 409 с кодом conflict
+//
+// Приём доступен пользователям с правом обслуживания
+// и должен подключаться за APIKeyAuth.
+func Replication(rp Replica) http.Handler {
+	return requireAction(rp, storage.ActionMaintain, "", func(w http.ResponseWriter, r *http.Request) {
+		source := r.URL.Query().Get("source")
+		if source == "" {
+			writeCode(w, r, CodeBadRequest)
+			return
+		}
+		var pos int64
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+			pos, err = rp.ReplicaPosition(r.Context(), source)
+		case http.MethodPost:
+			var after int64
+			var in []ReplicaEvent
+			after, err = strconv.ParseInt(r.URL.Query().Get("after"), 10, 64)
+			if err == nil {
+				err = json.NewDecoder(r.Body).Decode(&in)
+			}
+			if err != nil {
+				writeCode(w, r, CodeBadRequest)
+				return
+			}
+			events := make([]storage.Event, len(in))
+			for i, e := range in {
+				events[i] = storage.Event{
+					ID:      e.ID,
+					Created: e.Created,
+					Type:    e.Type,
+					TaskID:  e.TaskID,
+					Payload: e.Payload,
+				}
+			}
+			pos, err = rp.ApplyEvents(r.Context(), source, after, events)
+		default:
+			writeCode(w, r, CodeMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		writeJSON(w, replicaPosition{pos})
+	})
+}
+
+// Replicate передаёт события outbox из src приёмнику Replication
+// по адресу endpoint от имени экземпляра source, пока не отменён ctx
+// или не произошла ошибка. Передача начинается с позиции приёмника,
+// при отсутствии новых событий лента опрашивается с интервалом poll.
+// Лента EventsAfter не пропускает события параллельных транзакций,
+// а метки задач передаются событиями storage.EventTaskLabels.
+// Если позицию приёмника тем временем сдвинула другая передача,
+// Replicate завершается ошибкой, и передачу можно начать заново.
+// Ключ API apiKey передаётся в заголовке Authorization.
+// Если экземпляров несколько, передачу следует запускать через
+// storage.RunExclusive.
+func Replicate(ctx context.Context, src EventSource, client *http.Client, endpoint, apiKey, source string, poll time.Duration) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("source", source)
+	u.RawQuery = q.Encode()
+	target := u.String()
+
+	var pos replicaPosition
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	if err = doJSON(client, req, &pos); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		events, err := src.EventsAfter(ctx, pos.Position, sseBatch)
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			out := make([]ReplicaEvent, len(events))
+			for i, e := range events {
+				out[i] = ReplicaEvent{
+					ID:      e.ID,
+					Created: e.Created,
+					Type:    e.Type,
+					TaskID:  e.TaskID,
+					Payload: e.Payload,
+				}
+			}
+			body, err := json.Marshal(out)
+			if err != nil {
+				return err
+			}
+			q.Set("after", strconv.FormatInt(pos.Position, 10))
+			u.RawQuery = q.Encode()
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+			req.Header.Set("Content-Type", "application/json")
+			if err = doJSON(client, req, &pos); err != nil {
+				return err
+			}
+		}
+		if len(events) == sseBatch {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
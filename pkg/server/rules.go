@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"30-5/pkg/storage"
+)
+
+// RuleStore - правила автоматического назначения, например *storage.Storage.
+type RuleStore interface {
+	PermissionChecker
+	AssignmentRules(ctx context.Context) ([]storage.AssignmentRule, error)
+	NewAssignmentRule(ctx context.Context, r storage.AssignmentRule) (int, error)
+	DeleteAssignmentRule(ctx context.Context, id int) error
+}
+
+// AssignmentRules регистрирует в mux маршруты управления правилами
+// назначения ответственных:
+//
+//	GET    /assignment-rules      - список правил в порядке проверки
+//	POST   /assignment-rules      - создание правила из JSON, ответ - {"id": ...}
+//	DELETE /assignment-rules/{id} - удаление правила
+//
+// Маршруты доступны пользователям с правом управления правилами
+// и должны подключаться за SessionAuth или APIKeyAuth.
+func AssignmentRules(mux *http.ServeMux, rs RuleStore) {
+	mux.Handle("/assignment-rules", requireAction(rs, storage.ActionManageRules, "", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			rules, err := rs.AssignmentRules(r.Context())
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			if rules == nil {
+				rules = []storage.AssignmentRule{}
+			}
+			writeJSON(w, rules)
+		case http.MethodPost:
+			var rule storage.AssignmentRule
+			if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+				writeCode(w, r, CodeBadRequest)
+				return
+			}
+			id, err := rs.NewAssignmentRule(r.Context(), rule)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusCreated)
+			writeJSON(w, map[string]int{"id": id})
+		default:
+			writeCode(w, r, CodeMethodNotAllowed)
+		}
+	}))
+	mux.Handle("/assignment-rules/", requireAction(rs, storage.ActionManageRules, http.MethodDelete, func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/assignment-rules/"))
+		if err != nil {
+			writeCode(w, r, CodeNotFound)
+			return
+		}
+		if err = rs.DeleteAssignmentRule(r.Context(), id); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
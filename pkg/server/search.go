@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"30-5/pkg/storage"
+)
+
+// TaskSearcher - поиск задач по заголовку, например *storage.Storage.
+type TaskSearcher interface {
+	TasksByTitle(ctx context.Context, pattern string, limit int) ([]storage.Task, error)
+}
+
+// Число результатов поиска по умолчанию и наибольшее допустимое.
+const (
+	searchLimit    = 20
+	maxSearchLimit = 100
+)
+
+// Результат поиска: задача и её заголовок с выделенными совпадениями.
+type SearchHit struct {
+	storage.Task
+	// Highlight - заголовок, экранированный для HTML, в котором
+	// вхождения запроса обёрнуты в <mark>.
+	Highlight string `json:"highlight"`
+}
+
+// Search возвращает обработчик GET-запроса поиска задач по подстроке
+// заголовка из параметра q. Параметр limit ограничивает число
+// результатов: по умолчанию searchLimit, не больше maxSearchLimit.
+// Ответ - массив SearchHit в формате JSON.
+func Search(s TaskSearcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeCode(w, r, CodeMethodNotAllowed)
+			return
+		}
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		if q == "" {
+			writeCode(w, r, CodeBadRequest)
+			return
+		}
+		limit := searchLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				writeCode(w, r, CodeBadRequest)
+				return
+			}
+			limit = n
+		}
+		if limit > maxSearchLimit {
+			limit = maxSearchLimit
+		}
+		tasks, err := s.TasksByTitle(r.Context(), q, limit)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		hits := make([]SearchHit, 0, len(tasks))
+		for _, t := range tasks {
+			hits = append(hits, SearchHit{Task: t, Highlight: highlight(t.Title, q)})
+		}
+		writeJSON(w, hits)
+	})
+}
+
+// highlight экранирует text для HTML и оборачивает в <mark> все
+// непересекающиеся вхождения q без учёта регистра.
+func highlight(text, q string) string {
+	if q == "" {
+		return html.EscapeString(text)
+	}
+	src, pat := []rune(text), []rune(strings.ToLower(q))
+	var sb strings.Builder
+	start := 0
+	for i := 0; i+len(pat) <= len(src); {
+		if !hasFoldPrefix(src[i:], pat) {
+			i++
+			continue
+		}
+		sb.WriteString(html.EscapeString(string(src[start:i])))
+		sb.WriteString("<mark>" + html.EscapeString(string(src[i:i+len(pat)])) + "</mark>")
+		i += len(pat)
+		start = i
+	}
+	sb.WriteString(html.EscapeString(string(src[start:])))
+	return sb.String()
+}
+
+// hasFoldPrefix сообщает, начинается ли s с pat в нижнем регистре.
+func hasFoldPrefix(s, pat []rune) bool {
+	for j, p := range pat {
+		if unicode.ToLower(s[j]) != p {
+			return false
+		}
+	}
+	return true
+}
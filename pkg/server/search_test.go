@@ -0,0 +1,30 @@
+package server
+
+import "testing"
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text, q string
+		want    string
+	}{
+		{"Fix bug", "bug", "Fix <mark>bug</mark>"},
+		{"Fix BUG", "bug", "Fix <mark>BUG</mark>"},
+		{"Fix bug", "BUG", "Fix <mark>bug</mark>"},
+		{"bug in bug", "bug", "<mark>bug</mark> in <mark>bug</mark>"},
+		{"aaaa", "aa", "<mark>aa</mark><mark>aa</mark>"},
+		{"aaa", "aa", "<mark>aa</mark>a"},
+		{"Fix bug", "task", "Fix bug"},
+		{"", "bug", ""},
+		{"Fix bug", "", "Fix bug"},
+		{"<b>bug</b> & co", "bug", "&lt;b&gt;<mark>bug</mark>&lt;/b&gt; &amp; co"},
+		{"a<b", "<", "a<mark>&lt;</mark>b"},
+		{"Исправить Ошибку", "ошибку", "Исправить <mark>Ошибку</mark>"},
+		{"ЁЖИК и ёжик", "Ёжик", "<mark>ЁЖИК</mark> и <mark>ёжик</mark>"},
+		{"日本語のタスク", "タスク", "日本語の<mark>タスク</mark>"},
+	}
+	for _, tt := range tests {
+		if got := highlight(tt.text, tt.q); got != tt.want {
+			t.Errorf("highlight(%q, %q) = %q, want %q", tt.text, tt.q, got, tt.want)
+		}
+	}
+}
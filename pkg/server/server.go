@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Closer освобождает ресурсы после остановки сервера,
+// например *storage.Storage.
+type Closer interface {
+	Close()
+}
+
+// ключ контекста запроса для сигнала остановки сервера
+type shutdownKey struct{}
+
+// Run запускает srv и работает до отмены контекста. После отмены
+// сервер перестаёт принимать соединения и до timeout ждёт завершения
+// обрабатываемых запросов, затем закрывает хранилище s.
+// Долгие обработчики, например потоки Events, завершаются
+// в начале остановки, не дожидаясь timeout.
+// Возвращает nil при штатной остановке.
+func Run(ctx context.Context, srv *http.Server, timeout time.Duration, s Closer) error {
+	defer s.Close()
+	stopping := make(chan struct{})
+	base := srv.BaseContext
+	srv.BaseContext = func(l net.Listener) context.Context {
+		ctx := context.Background()
+		if base != nil {
+			ctx = base(l)
+		}
+		return context.WithValue(ctx, shutdownKey{}, (<-chan struct{})(stopping))
+	}
+	srv.RegisterOnShutdown(func() { close(stopping) })
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if lerr := <-errc; !errors.Is(lerr, http.ErrServerClosed) && err == nil {
+		err = lerr
+	}
+	return err
+}
+
+// shuttingDown возвращает канал, закрываемый в начале остановки
+// сервера Run, или nil, если запрос обслуживается не через Run.
+func shuttingDown(ctx context.Context) <-chan struct{} {
+	stop, _ := ctx.Value(shutdownKey{}).(<-chan struct{})
+	return stop
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"30-5/pkg/storage"
+)
+
+// SessionCookie - имя cookie с токеном сессии.
+const SessionCookie = "session"
+
+// SessionStore хранит сессии, например *storage.Storage.
+type SessionStore interface {
+	CreateSession(ctx context.Context, userID int, ttl time.Duration) (string, error)
+	SessionByToken(ctx context.Context, token string) (storage.Session, error)
+	RevokeSession(ctx context.Context, token string) error
+}
+
+// ключ контекста для id пользователя запроса
+type userKey struct{}
+
+// UserID возвращает id пользователя, от имени которого выполняется
+// запрос, установленный SessionAuth или APIKeyAuth.
+func UserID(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(userKey{}).(int)
+	return id, ok
+}
+
+// StartSession открывает сессию пользователя userID сроком ttl
+// и передаёт её токен клиенту в cookie.
+// Вызывается обработчиком входа после проверки учётных данных.
+func StartSession(w http.ResponseWriter, r *http.Request, store SessionStore, userID int, ttl time.Duration) error {
+	token, err := store.CreateSession(r.Context(), userID, ttl)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(ttl),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// SessionAuth пропускает к next только запросы с активной сессией.
+func SessionAuth(store SessionStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(SessionCookie)
+		if err != nil {
+			writeCode(w, r, CodeUnauthorized)
+			return
+		}
+		sess, err := store.SessionByToken(r.Context(), c.Value)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), userKey{}, sess.UserID)
+		ctx = storage.WithActor(ctx, sess.UserID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Logout возвращает обработчик выхода: завершает сессию
+// и удаляет cookie клиента.
+func Logout(store SessionStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeCode(w, r, CodeMethodNotAllowed)
+			return
+		}
+		if c, err := r.Cookie(SessionCookie); err == nil {
+			if err = store.RevokeSession(r.Context(), c.Value); err != nil {
+				WriteError(w, r, err)
+				return
+			}
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     SessionCookie,
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+		})
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// PasswordVerifier проверяет учётные данные, например *storage.Storage.
+type PasswordVerifier interface {
+	VerifyPassword(ctx context.Context, email, password string) (storage.User, error)
+}
+
+// Login возвращает обработчик входа по паролю: принимает POST
+// с полями формы email и password и открывает сессию сроком ttl.
+func Login(v PasswordVerifier, store SessionStore, ttl time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeCode(w, r, CodeMethodNotAllowed)
+			return
+		}
+		u, err := v.VerifyPassword(r.Context(), r.PostFormValue("email"), r.PostFormValue("password"))
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if err = StartSession(w, r, store, u.ID, ttl); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
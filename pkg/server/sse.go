@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"30-5/pkg/storage"
+)
+
+// EventSource - лента событий задач, например *storage.Storage.
+type EventSource interface {
+	EventsAfter(ctx context.Context, afterID int64, limit int) ([]storage.Event, error)
+	LastEventID(ctx context.Context) (int64, error)
+}
+
+// число событий, читаемых за один опрос
+const sseBatch = 100
+
+// Events возвращает обработчик потока событий задач в формате
+// Server-Sent Events. Лента опрашивается с интервалом poll.
+// Каждое сообщение несёт id события outbox, тип события и состояние
+// задачи в JSON; клиент, переподключившийся с заголовком Last-Event-ID,
+// получает пропущенные события. Без заголовка поток начинается
+// с новых событий. При остановке сервера через Run поток закрывается,
+// и клиент переподключается к другому экземпляру.
+func Events(src EventSource, poll time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeCode(w, r, CodeInternal)
+			return
+		}
+		ctx := r.Context()
+		var last int64
+		if v := r.Header.Get("Last-Event-ID"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeCode(w, r, CodeBadRequest)
+				return
+			}
+			last = id
+		} else {
+			id, err := src.LastEventID(ctx)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			last = id
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		stop := shuttingDown(ctx)
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+		for {
+			events, err := src.EventsAfter(ctx, last, sseBatch)
+			if err != nil {
+				// клиент переподключится с последним полученным id
+				return
+			}
+			for _, e := range events {
+				_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Payload)
+				if err != nil {
+					return
+				}
+				last = e.ID
+			}
+			if len(events) > 0 {
+				flusher.Flush()
+			}
+			if len(events) == sseBatch {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
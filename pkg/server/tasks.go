@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"30-5/pkg/storage"
+)
+
+// TaskLister - список задач с отпечатком для ETag, например *storage.Storage.
+type TaskLister interface {
+	TasksByFilter(ctx context.Context, f storage.TaskFilter) ([]storage.Task, error)
+	TasksFingerprint(ctx context.Context, f storage.TaskFilter) (string, error)
+}
+
+// Tasks возвращает обработчик GET-запроса списка задач в формате JSON.
+// Фильтр задаётся параметрами author, assigned, label, epic, limit,
+// offset и after. Ответ несёт ETag из отпечатка списка; на запрос
+// с совпадающим If-None-Match отвечает 304 без выборки задач.
+// Отпечаток не замечает смены меток и повторного изменения задачи
+// в ту же секунду (см. storage.TasksFingerprint), поэтому ответ
+// помечается Cache-Control: no-cache и клиент перепроверяет список
+// при каждом опросе.
+func Tasks(l TaskLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeCode(w, r, CodeMethodNotAllowed)
+			return
+		}
+		f, ok := taskFilter(r)
+		if !ok {
+			writeCode(w, r, CodeBadRequest)
+			return
+		}
+		fp, err := l.TasksFingerprint(r.Context(), f)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		// постраничные параметры входят в ETag: отпечаток общий для страниц
+		etag := `"` + fp + "." + strconv.Itoa(f.Limit) + "." + strconv.Itoa(f.Offset) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		tasks, err := l.TasksByFilter(r.Context(), f)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if tasks == nil {
+			tasks = []storage.Task{}
+		}
+		writeJSON(w, tasks)
+	})
+}
+
+// taskFilter разбирает фильтр списка задач из параметров запроса
+// и сообщает, корректны ли они.
+func taskFilter(r *http.Request) (storage.TaskFilter, bool) {
+	q := r.URL.Query()
+	f := storage.TaskFilter{Label: q.Get("label")}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{
+		{"author", &f.AuthorID},
+		{"assigned", &f.AssignedID},
+		{"epic", &f.EpicID},
+		{"limit", &f.Limit},
+		{"offset", &f.Offset},
+		{"after", &f.AfterID},
+	} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, false
+		}
+		*p.dst = n
+	}
+	return f, true
+}
+
+// etagMatch сообщает, совпадает ли etag с одним из значений
+// заголовка If-None-Match (слабое сравнение).
+func etagMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
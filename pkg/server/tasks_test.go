@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"30-5/pkg/storage"
+)
+
+func TestEtagMatch(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz"`, false},
+		{`"xyz", "abc"`, true},
+		{`"xyz",W/"abc"`, true},
+		{"*", true},
+		{`abc`, false},
+		{`"ABC"`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatch(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatch(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestTaskFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		want  storage.TaskFilter
+		ok    bool
+	}{
+		{"", storage.TaskFilter{}, true},
+		{"author=1&assigned=2&epic=3", storage.TaskFilter{AuthorID: 1, AssignedID: 2, EpicID: 3}, true},
+		{"label=bug&limit=10&offset=20", storage.TaskFilter{Label: "bug", Limit: 10, Offset: 20}, true},
+		{"after=42", storage.TaskFilter{AfterID: 42}, true},
+		{"author=", storage.TaskFilter{}, true},
+		{"author=x", storage.TaskFilter{}, false},
+		{"limit=-1", storage.TaskFilter{}, false},
+		{"offset=1.5", storage.TaskFilter{}, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/tasks?"+tt.query, nil)
+		got, ok := taskFilter(r)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("taskFilter(%q) = %+v, %v; want %+v, %v", tt.query, got, ok, tt.want, tt.ok)
+		}
+	}
+}
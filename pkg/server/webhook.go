@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"30-5/pkg/storage"
+)
+
+// TaskCreator создаёт задачи, например *storage.Storage.
+type TaskCreator interface {
+	NewTaskWithLabels(ctx context.Context, t storage.Task, labels []string) (storage.Task, error)
+}
+
+// Настройка входящего вебхука: отображение произвольного JSON
+// в поля задачи. Title, Content и Labels - шаблоны text/template,
+// которые получают тело запроса, разобранное в map[string]any,
+// например {{.alert.summary}}; отсутствующие поля дают пустую строку,
+// пустые метки пропускаются.
+type WebhookConfig struct {
+	// Token - секрет вебхука, передаваемый в заголовке X-Webhook-Token
+	// или параметре token (для отправителей, не умеющих задавать
+	// заголовки). Пустой секрет отвергает все запросы.
+	Token   string
+	Title   string
+	Content string
+	Labels  []string
+	// Значения полей задачи, не зависящие от тела запроса:
+	// внешний отправитель не может назначить задачу кому угодно.
+	Priority   int
+	AuthorID   int
+	AssignedID int
+}
+
+// Разобранные шаблоны вебхука.
+type webhook struct {
+	cfg     WebhookConfig
+	title   *template.Template
+	content *template.Template
+	labels  []*template.Template
+}
+
+// Webhook возвращает обработчик входящего вебхука, создающий задачу
+// из каждого POST-запроса с JSON-телом по настройке cfg. Ответ - 201
+// с созданной задачей. Шаблоны разбираются один раз, и некорректный
+// шаблон - ошибка настройки, возвращаемая Webhook, а не ответ 500.
+func Webhook(c TaskCreator, cfg WebhookConfig) (http.Handler, error) {
+	h := webhook{cfg: cfg}
+	var err error
+	if h.title, err = parseField("title", cfg.Title); err != nil {
+		return nil, err
+	}
+	if h.content, err = parseField("content", cfg.Content); err != nil {
+		return nil, err
+	}
+	for _, l := range cfg.Labels {
+		t, err := parseField("label", l)
+		if err != nil {
+			return nil, err
+		}
+		h.labels = append(h.labels, t)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeCode(w, r, CodeMethodNotAllowed)
+			return
+		}
+		token := r.Header.Get("X-Webhook-Token")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if cfg.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) != 1 {
+			writeCode(w, r, CodeUnauthorized)
+			return
+		}
+		var payload map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeCode(w, r, CodeBadRequest)
+			return
+		}
+		t, labels, err := h.task(payload)
+		if err != nil {
+			writeCode(w, r, CodeBadRequest)
+			return
+		}
+		created, err := c.NewTaskWithLabels(r.Context(), t, labels)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}), nil
+}
+
+// parseField разбирает шаблон поля задачи.
+func parseField(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=zero").Parse(text)
+}
+
+// task отображает тело запроса в задачу и её метки.
+func (h webhook) task(payload map[string]any) (storage.Task, []string, error) {
+	title, err := execField(h.title, payload)
+	if err != nil {
+		return storage.Task{}, nil, err
+	}
+	content, err := execField(h.content, payload)
+	if err != nil {
+		return storage.Task{}, nil, err
+	}
+	var labels []string
+	for _, lt := range h.labels {
+		l, err := execField(lt, payload)
+		if err != nil {
+			return storage.Task{}, nil, err
+		}
+		if l != "" {
+			labels = append(labels, l)
+		}
+	}
+	return storage.Task{
+		Title:      title,
+		Content:    content,
+		Priority:   h.cfg.Priority,
+		AuthorID:   h.cfg.AuthorID,
+		AssignedID: h.cfg.AssignedID,
+	}, labels, nil
+}
+
+// execField применяет шаблон поля к телу запроса.
+func execField(t *template.Template, payload map[string]any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, payload); err != nil {
+		return "", err
+	}
+	// для отсутствующих ключей карты шаблон выводит "<no value>"
+	return strings.TrimSpace(strings.ReplaceAll(buf.String(), "<no value>", "")), nil
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Состояние пула соединений.
+type PoolStats struct {
+	MaxConns        int32
+	TotalConns      int32
+	IdleConns       int32
+	AcquiredConns   int32
+	AcquireCount    int64
+	AcquireDuration time.Duration // суммарное ожидание соединений
+	EmptyAcquire    int64         // получения, ожидавшие свободного соединения
+}
+
+// PoolStats возвращает состояние пула соединений.
+func (s *Storage) PoolStats() PoolStats {
+	st := s.db.Stat()
+	return PoolStats{
+		MaxConns:        st.MaxConns(),
+		TotalConns:      st.TotalConns(),
+		IdleConns:       st.IdleConns(),
+		AcquiredConns:   st.AcquiredConns(),
+		AcquireCount:    st.AcquireCount(),
+		AcquireDuration: st.AcquireDuration(),
+		EmptyAcquire:    st.EmptyAcquireCount(),
+	}
+}
+
+// Рекомендация по обслуживанию таблицы или индекса.
+type MaintenanceHint struct {
+	Object string // таблица или индекс
+	Action string // "VACUUM" или "DROP INDEX"
+	Reason string
+}
+
+// MaintenanceHints возвращает рекомендации по обслуживанию по статистике
+// сервера: таблицы, где мёртвых строк больше пятой части, стоит
+// очистить, а индексы, ни разу не использованные с момента сброса
+// статистики, - проверить и удалить.
+func (s *Storage) MaintenanceHints(ctx context.Context) ([]MaintenanceHint, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT relname, 'VACUUM', n_dead_tup, n_live_tup
+		FROM pg_stat_user_tables
+		WHERE n_dead_tup > 1000 AND n_dead_tup > n_live_tup / 5
+		UNION ALL
+		SELECT i.indexrelname, 'DROP INDEX', 0, 0
+		FROM pg_stat_user_indexes i
+		JOIN pg_index x ON x.indexrelid = i.indexrelid
+		WHERE i.idx_scan = 0 AND NOT x.indisunique AND NOT x.indisprimary
+		ORDER BY 2, 1;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var hints []MaintenanceHint
+	for rows.Next() {
+		var h MaintenanceHint
+		var dead, live int64
+		if err = rows.Scan(&h.Object, &h.Action, &dead, &live); err != nil {
+			return nil, err
+		}
+		if h.Action == "VACUUM" {
+			h.Reason = fmt.Sprintf("%d dead rows, %d live", dead, live)
+		} else {
+			h.Reason = "index has never been scanned"
+		}
+		hints = append(hints, h)
+	}
+	return hints, rows.Err()
+}
+
+// Число строк, удалённых CleanupOrphans.
+type CleanupStats struct {
+	Sessions int // истёкшие и завершённые сессии
+	Jobs     int // задания удалённых пользователей
+}
+
+// CleanupOrphans удаляет строки, которые больше не могут понадобиться:
+// истёкшие и завершённые сессии и фоновые задания удалённых
+// пользователей (у jobs нет внешнего ключа на users). Метки без задач
+// не удаляются: это записи справочника, на которые по названию
+// ссылаются правила назначения, значения по умолчанию и настройки
+// уведомлений. В контексте WithDryRun ничего не удаляется,
+// а результат показывает, что было бы удалено.
+func (s *Storage) CleanupOrphans(ctx context.Context) (CleanupStats, error) {
+	if err := s.checkWritable(); err != nil {
+		return CleanupStats{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return CleanupStats{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var stats CleanupStats
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM sessions
+		WHERE expires <= extract(epoch from now()) OR revoked <> 0;
+	`)
+	if err != nil {
+		return CleanupStats{}, err
+	}
+	stats.Sessions = int(tag.RowsAffected())
+	tag, err = tx.Exec(ctx, `
+		DELETE FROM jobs j
+		WHERE j.user_id <> 0 AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = j.user_id);
+	`)
+	if err != nil {
+		return CleanupStats{}, err
+	}
+	stats.Jobs = int(tag.RowsAffected())
+	return stats, finish(ctx, tx)
+}
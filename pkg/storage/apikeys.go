@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrInvalidAPIKey возвращается для неизвестного или отозванного ключа API.
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// ErrAPIKeyNotFound возвращается, когда ключа с указанным id нет.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// префикс ключей API, позволяет опознать ключ в утёкших данных
+const apiKeyPrefix = "tk_"
+
+// Ключ API. Сам ключ не хранится и возвращается только при создании.
+type APIKey struct {
+	ID       int
+	UserID   int // владелец, от имени которого выполняются действия
+	Name     string
+	Prefix   string // начало ключа для опознания в списке
+	Scopes   []string
+	Created  int64
+	LastUsed int64
+	Revoked  int64 // 0 - ключ действует
+}
+
+// HasScope сообщает, разрешена ли ключу область scope.
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// столбцы ключа API
+const apiKeyColumns = `id, user_id, name, prefix, scopes, created, last_used, revoked`
+
+func scanAPIKey(row pgx.Row) (APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.Created, &k.LastUsed, &k.Revoked)
+	return k, err
+}
+
+// CreateAPIKey создаёт ключ API пользователя userID с областями scopes
+// и возвращает его описание и сам ключ, который больше нигде не хранится.
+func (s *Storage) CreateAPIKey(ctx context.Context, userID int, name string, scopes []string) (APIKey, string, error) {
+	if err := s.checkWritable(); err != nil {
+		return APIKey{}, "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return APIKey{}, "", err
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	hash := sha256.Sum256([]byte(secret))
+	if scopes == nil {
+		scopes = []string{}
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	defer tx.Rollback(ctx)
+	k, err := scanAPIKey(tx.QueryRow(ctx, `
+		INSERT INTO api_keys (user_id, name, prefix, hash, scopes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+apiKeyColumns+`;
+	`,
+		userID,
+		name,
+		secret[:len(apiKeyPrefix)+6],
+		hash[:],
+		scopes,
+	))
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	err = addAudit(ctx, tx, AuditAPIKeyCreate, target("api_key", k.ID), map[string]any{
+		"user_id": userID,
+		"scopes":  scopes,
+	})
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return APIKey{}, "", err
+	}
+	return k, secret, nil
+}
+
+// APIKeys возвращает ключи пользователя, включая отозванные.
+func (s *Storage) APIKeys(ctx context.Context, userID int) ([]APIKey, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY id;
+	`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// SetAPIKeyScopes заменяет области действия ключа.
+func (s *Storage) SetAPIKeyScopes(ctx context.Context, id int, scopes []string) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tag, err := s.db.Exec(ctx, `UPDATE api_keys SET scopes = $2 WHERE id = $1;`, id, scopes)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// RevokeAPIKey отзывает ключ. Повторный отзыв не меняет время отзыва.
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, `
+		UPDATE api_keys
+		SET revoked = CASE WHEN revoked = 0 THEN extract(epoch from now()) ELSE revoked END
+		WHERE id = $1;
+	`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	if err = addAudit(ctx, tx, AuditAPIKeyRevoke, target("api_key", id), nil); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// AuthenticateAPIKey возвращает действующий ключ по его значению
+// и отмечает время использования. Для неизвестного или отозванного
+// ключа возвращается ErrInvalidAPIKey.
+func (s *Storage) AuthenticateAPIKey(ctx context.Context, secret string) (APIKey, error) {
+	hash := sha256.Sum256([]byte(secret))
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	query := `
+		UPDATE api_keys
+		SET last_used = extract(epoch from now())
+		WHERE hash = $1 AND revoked = 0
+		RETURNING ` + apiKeyColumns + `;
+	`
+	if s.cfg.readOnly {
+		query = `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE hash = $1 AND revoked = 0;`
+	}
+	k, err := scanAPIKey(s.db.QueryRow(ctx, query, hash[:]))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+	return k, nil
+}
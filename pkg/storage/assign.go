@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrRuleNotFound возвращается, если правила назначения с таким id нет.
+var ErrRuleNotFound = errors.New("assignment rule not found")
+
+// ErrInvalidRule возвращается при создании правила без ответственных
+// или с неизвестной стратегией.
+var ErrInvalidRule = errors.New("invalid assignment rule")
+
+// Стратегия выбора ответственного из команды правила.
+type Strategy string
+
+const (
+	// StrategyRoundRobin - участники команды по очереди.
+	StrategyRoundRobin Strategy = "round_robin"
+	// StrategyLeastOpen - участник с наименьшим числом открытых задач,
+	// при равенстве - первый в списке команды.
+	StrategyLeastOpen Strategy = "least_open"
+)
+
+// strategies - запросы выбора ответственного по стратегиям.
+// Параметр запроса - id правила, строка которого уже заблокирована
+// транзакцией; новая стратегия добавляется записью в эту таблицу.
+var strategies = map[Strategy]string{
+	StrategyRoundRobin: `
+		UPDATE assignment_rules r
+		SET cursor = r.cursor + 1
+		WHERE r.id = $1
+		RETURNING r.assignees[(r.cursor - 1) % cardinality(r.assignees) + 1];
+	`,
+	StrategyLeastOpen: `
+		SELECT m.assignee
+		FROM assignment_rules r
+		CROSS JOIN unnest(r.assignees) WITH ORDINALITY AS m(assignee, n)
+		LEFT JOIN tasks t ON
+			t.assigned_id = m.assignee AND
+			t.closed = 0 AND
+			NOT t.draft
+		WHERE r.id = $1
+		GROUP BY m.assignee, m.n
+		ORDER BY count(t.id), m.n
+		LIMIT 1;
+	`,
+}
+
+// Правило автоматического назначения ответственного. Задаче,
+// созданной без ответственного, назначается участник команды Assignees
+// первого подходящего правила, выбранный стратегией Strategy
+// (по умолчанию StrategyRoundRobin).
+type AssignmentRule struct {
+	ID        int      `json:"id"`
+	Position  int      `json:"position"` // порядок проверки правил
+	Label     string   `json:"label"`    // метка задачи, "" - любая
+	EpicID    int      `json:"epic_id"`  // эпик задачи, 0 - любой
+	Assignees []int    `json:"assignees"`
+	Strategy  Strategy `json:"strategy"`
+}
+
+// AssignmentRules возвращает правила назначения в порядке проверки.
+func (s *Storage) AssignmentRules(ctx context.Context) ([]AssignmentRule, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT id, position, label, coalesce(epic_id, 0), assignees, strategy
+		FROM assignment_rules
+		ORDER BY position, id;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules []AssignmentRule
+	for rows.Next() {
+		var r AssignmentRule
+		if err = rows.Scan(&r.ID, &r.Position, &r.Label, &r.EpicID, &r.Assignees, &r.Strategy); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// NewAssignmentRule создаёт правило назначения и возвращает его id.
+func (s *Storage) NewAssignmentRule(ctx context.Context, r AssignmentRule) (int, error) {
+	if err := s.checkWritable(); err != nil {
+		return 0, err
+	}
+	if r.Strategy == "" {
+		r.Strategy = StrategyRoundRobin
+	}
+	if _, ok := strategies[r.Strategy]; !ok || len(r.Assignees) == 0 {
+		return 0, ErrInvalidRule
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	var id int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO assignment_rules (position, label, epic_id, assignees, strategy)
+		VALUES ($1, $2, nullif($3, 0), $4, $5)
+		RETURNING id;
+	`,
+		r.Position,
+		r.Label,
+		r.EpicID,
+		r.Assignees,
+		string(r.Strategy),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	err = addAudit(ctx, tx, AuditRuleCreate, target("rule", id), map[string]any{
+		"label":     r.Label,
+		"epic_id":   r.EpicID,
+		"assignees": r.Assignees,
+		"strategy":  r.Strategy,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
+}
+
+// DeleteAssignmentRule удаляет правило назначения.
+func (s *Storage) DeleteAssignmentRule(ctx context.Context, id int) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, `DELETE FROM assignment_rules WHERE id = $1;`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRuleNotFound
+	}
+	if err = addAudit(ctx, tx, AuditRuleDelete, target("rule", id), nil); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// assignByRules возвращает ответственного за задачу t с метками labels
+// по первому подходящему правилу назначения, 0 - если правил нет.
+// Строка правила блокируется до конца транзакции tx, поэтому
+// параллельные создания задач по одному правилу выполняются по очереди,
+// и запрос стратегии каждого из них видит задачи, назначенные
+// предыдущими.
+func assignByRules(ctx context.Context, tx pgx.Tx, t Task, labels []string) (int, error) {
+	if labels == nil {
+		labels = []string{}
+	}
+	var id int
+	var strategy string
+	err := tx.QueryRow(ctx, `
+		SELECT id, strategy FROM assignment_rules
+		WHERE
+			(label = '' OR label = ANY($1)) AND
+			(epic_id IS NULL OR epic_id = $2) AND
+			cardinality(assignees) > 0
+		ORDER BY position, id
+		LIMIT 1
+		FOR UPDATE;
+	`,
+		labels,
+		t.EpicID,
+	).Scan(&id, &strategy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	query, ok := strategies[Strategy(strategy)]
+	if !ok {
+		query = strategies[StrategyRoundRobin]
+	}
+	var assignee int
+	err = tx.QueryRow(ctx, query, id).Scan(&assignee)
+	return assignee, err
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+// Действия, записываемые в журнал аудита.
+const (
+	AuditLogin          = "auth.login"
+	AuditLoginFailed    = "auth.login_failed"
+	AuditPasswordChange = "auth.password_change"
+	AuditAPIKeyCreate   = "auth.api_key_create"
+	AuditAPIKeyRevoke   = "auth.api_key_revoke"
+	AuditRoleChange     = "user.role_change"
+	AuditTaskDelete     = "task.delete"
+	AuditRuleCreate     = "rule.create"
+	AuditRuleDelete     = "rule.delete"
+)
+
+// Запись журнала аудита.
+type AuditEntry struct {
+	ID      int64
+	Created int64
+	ActorID int // пользователь, выполнивший действие, 0 - неизвестен
+	Action  string
+	Target  string // объект действия, например "task:42"
+	Detail  map[string]any
+}
+
+// Фильтр журнала аудита. Поля с нулевыми значениями выборку не ограничивают.
+type AuditFilter struct {
+	ActorID int
+	Action  string
+	Target  string
+	// Период в секундах Unix: нижняя граница включается, верхняя - нет.
+	After  int64
+	Before int64
+	Limit  int
+}
+
+// execer выполняет запрос в пуле или транзакции.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// addAudit записывает действие пользователя из контекста в журнал аудита;
+// внутри транзакции запись фиксируется вместе с действием.
+func addAudit(ctx context.Context, q execer, action, target string, detail map[string]any) error {
+	if detail == nil {
+		detail = map[string]any{}
+	}
+	b, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, `
+		INSERT INTO audit_log (actor_id, action, target, detail)
+		VALUES ($1, $2, $3, $4);
+		`,
+		Actor(ctx),
+		action,
+		target,
+		b,
+	)
+	return err
+}
+
+// target возвращает обозначение объекта действия вида "kind:id".
+func target(kind string, id int) string {
+	return kind + ":" + strconv.Itoa(id)
+}
+
+// AuditLog возвращает записи журнала аудита по фильтру,
+// начиная с последних.
+func (s *Storage) AuditLog(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	conds := []string{"TRUE"}
+	var args []any
+	add := func(cond string, v any) {
+		args = append(args, v)
+		conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if f.ActorID != 0 {
+		add("actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.Target != "" {
+		add("target = ?", f.Target)
+	}
+	if f.After != 0 {
+		add("created >= ?", f.After)
+	}
+	if f.Before != 0 {
+		add("created < ?", f.Before)
+	}
+	limit := ""
+	if f.Limit > 0 {
+		limit = " LIMIT " + strconv.Itoa(f.Limit)
+	}
+	rows, err := s.db.Query(ctx, `
+		SELECT id, created, actor_id, action, target, detail
+		FROM audit_log
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY id DESC`+limit+`;
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var detail []byte
+		err = rows.Scan(&e.ID, &e.Created, &e.ActorID, &e.Action, &e.Target, &detail)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(detail, &e.Detail); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrBatchAborted возвращается пакетной операцией в режиме
+// WithAllOrNothing, если хотя бы один элемент не обработан;
+// изменения остальных элементов при этом отменяются.
+var ErrBatchAborted = errors.New("batch aborted: some items failed")
+
+// ключ контекста для режима "всё или ничего".
+type allOrNothingKey struct{}
+
+// WithAllOrNothing включает для пакетных операций с возвращённым
+// контекстом режим "всё или ничего": при ошибке любого элемента
+// транзакция откатывается, а операция возвращает результаты
+// по элементам вместе с ErrBatchAborted. По умолчанию успешные
+// элементы фиксируются независимо от неудачных. Режим передаётся
+// через контекст, а не параметром, чтобы сигнатуры пакетных методов
+// в storage.Interface и его декораторах оставались прежними.
+func WithAllOrNothing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allOrNothingKey{}, true)
+}
+
+// IsAllOrNothing сообщает, включён ли в контексте режим "всё или ничего".
+func IsAllOrNothing(ctx context.Context) bool {
+	v, _ := ctx.Value(allOrNothingKey{}).(bool)
+	return v
+}
+
+// Результат пакетной операции: результаты по элементам
+// в порядке входных данных и их итоги.
+type BatchResult struct {
+	Items     []ItemResult
+	Succeeded int
+	Failed    int
+}
+
+// newBatchResult подсчитывает итоги результатов по элементам.
+func newBatchResult(items []ItemResult) BatchResult {
+	r := BatchResult{Items: items}
+	for _, it := range items {
+		if it.Err != nil {
+			r.Failed++
+		} else {
+			r.Succeeded++
+		}
+	}
+	return r
+}
+
+// commitBatch фиксирует транзакцию пакетной операции с результатами
+// results, а в режиме "всё или ничего" при неудачных элементах
+// оставляет её откатываться и возвращает ErrBatchAborted.
+func commitBatch(ctx context.Context, tx pgx.Tx, results []ItemResult) (BatchResult, error) {
+	r := newBatchResult(results)
+	if IsAllOrNothing(ctx) && r.Failed > 0 {
+		return r, ErrBatchAborted
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return BatchResult{}, err
+	}
+	return r, nil
+}
+
+// isItemError сообщает, относится ли ошибка err к самому элементу
+// пакета: это ошибки проверки задачи и нарушения ограничений БД
+// (классы 22 и 23), например ссылка на несуществующий эпик.
+// Остальные ошибки - обрыв соединения, отмена контекста - прерывают
+// всю пакетную операцию.
+func isItemError(err error) bool {
+	if errors.Is(err, ErrInvalidTask) || errors.Is(err, ErrQuotaExceeded) ||
+		errors.Is(err, ErrDuplicateUID) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		(strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23"))
+}
+
+// NewTasks создаёт задачи tasks в одной транзакции. Каждая задача
+// добавляется в своей точке сохранения, так что ошибка одной задачи
+// (ErrInvalidTask, ErrQuotaExceeded, ErrDuplicateUID) не мешает
+// остальным, если не включён режим WithAllOrNothing. Прочие ошибки
+// (обрыв соединения, отмена контекста) прерывают всю операцию
+// и откатывают транзакцию. Результаты возвращаются в порядке tasks;
+// ID результата - id созданной задачи.
+// К задачам применяются значения по умолчанию и правила назначения,
+// как в NewTask.
+func (s *Storage) NewTasks(ctx context.Context, tasks []Task) (BatchResult, error) {
+	if err := s.checkWritable(); err != nil {
+		return BatchResult{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	results := make([]ItemResult, len(tasks))
+	for i, t := range tasks {
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return BatchResult{}, err
+		}
+		created, err := s.createTask(ctx, sp, t, nil)
+		if err != nil && !isItemError(err) {
+			return BatchResult{}, err
+		}
+		if err != nil {
+			results[i].Err = err
+			if err = sp.Rollback(ctx); err != nil {
+				return BatchResult{}, err
+			}
+			continue
+		}
+		if err = sp.Commit(ctx); err != nil {
+			return BatchResult{}, err
+		}
+		results[i].ID = created.ID
+	}
+	return commitBatch(ctx, tx, results)
+}
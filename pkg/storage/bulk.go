@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrTooManyRows возвращается, когда под условие пакетного удаления
+// попадает больше задач, чем разрешено.
+var ErrTooManyRows = errors.New("too many rows match")
+
+// Результат пакетной операции для одной задачи.
+type ItemResult struct {
+	ID  int
+	Err error // nil при успехе, иначе ошибка элемента, например ErrTaskNotFound
+}
+
+// CloseTasks закрывает открытые задачи из списка ids одним запросом.
+// Уже закрытые задачи не изменяются и считаются успешно обработанными.
+// Результаты возвращаются в порядке ids.
+func (s *Storage) CloseTasks(ctx context.Context, ids []int) (BatchResult, error) {
+	if err := s.checkWritable(); err != nil {
+		return BatchResult{}, err
+	}
+	return s.bulkUpdate(ctx, ids, EventTaskClosed, `
+		UPDATE tasks AS t
+		SET closed = extract(epoch from now())
+		WHERE t.id = ANY($1) AND coalesce(t.closed, 0) = 0
+		RETURNING `+taskColumns+`;
+	`)
+}
+
+// ReassignTasks назначает ответственным за задачи из списка ids
+// пользователя newAssignee одним запросом.
+// Результаты возвращаются в порядке ids.
+func (s *Storage) ReassignTasks(ctx context.Context, ids []int, newAssignee int) (BatchResult, error) {
+	if err := s.checkWritable(); err != nil {
+		return BatchResult{}, err
+	}
+	return s.bulkUpdate(ctx, ids, EventTaskUpdated, `
+		UPDATE tasks AS t
+		SET assigned_id = $2
+		WHERE t.id = ANY($1) AND t.assigned_id IS DISTINCT FROM $2
+		RETURNING `+taskColumns+`;
+	`, newAssignee)
+}
+
+// bulkUpdate выполняет в транзакции запрос sql, изменяющий задачи
+// с id из первого параметра и возвращающий изменённые задачи,
+// записывает события типа typ и собирает результаты по каждому id.
+// Задачи, которые существуют, но не были изменены запросом,
+// считаются успешно обработанными.
+func (s *Storage) bulkUpdate(ctx context.Context, ids []int, typ, sql string, args ...any) (BatchResult, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, sql, append([]any{ids}, args...)...)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	updated, err := scanTasks(rows, len(ids))
+	if err != nil {
+		return BatchResult{}, err
+	}
+	for _, t := range updated {
+		if err = addEvent(ctx, tx, typ, t); err != nil {
+			return BatchResult{}, err
+		}
+	}
+	found, err := existingTasks(ctx, tx, ids)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	return commitBatch(ctx, tx, itemResults(ids, found))
+}
+
+// itemResults возвращает результаты пакетной операции в порядке ids:
+// задачи, отсутствующие в found, получают ErrTaskNotFound.
+func itemResults(ids []int, found map[int]bool) []ItemResult {
+	results := make([]ItemResult, len(ids))
+	for i, id := range ids {
+		results[i].ID = id
+		if !found[id] {
+			results[i].Err = ErrTaskNotFound
+		}
+	}
+	return results
+}
+
+// existingTasks возвращает множество id из ids, для которых существуют задачи.
+func existingTasks(ctx context.Context, tx pgx.Tx, ids []int) (map[int]bool, error) {
+	rows, err := tx.Query(ctx, `SELECT id FROM tasks WHERE id = ANY($1);`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	found := make(map[int]bool, len(ids))
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		found[id] = true
+	}
+	return found, rows.Err()
+}
+
+// Число строк, удалённых вместе с задачами.
+type DeleteStats struct {
+	Tasks  int // задачи
+	Labels int // связи задач с метками
+}
+
+// DeleteTasksWhere удаляет все задачи, подходящие под фильтр, вместе с их
+// связями с метками. Limit и Offset фильтра не учитываются, отложенные
+// задачи удаляются наравне с остальными. Если подходящих задач больше
+// maxRows, ничего не удаляется и возвращается ErrTooManyRows -
+// это защищает от случайного массового удаления из-за ошибки в фильтре.
+// В контексте WithDryRun задачи не удаляются, а результат показывает,
+// что было бы удалено.
+func (s *Storage) DeleteTasksWhere(ctx context.Context, f TaskFilter, maxRows int) (DeleteStats, error) {
+	if err := s.checkWritable(); err != nil {
+		return DeleteStats{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return DeleteStats{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// блокировка подходящих задач, чтобы набор не изменился до удаления
+	f.Snoozed = true
+	where, args := f.where(nil)
+	rows, err := tx.Query(ctx, `
+		SELECT t.id FROM tasks t
+		WHERE `+where+`
+		FOR UPDATE;
+	`,
+		args...,
+	)
+	if err != nil {
+		return DeleteStats{}, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return DeleteStats{}, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return DeleteStats{}, err
+	}
+	if len(ids) > maxRows {
+		return DeleteStats{}, ErrTooManyRows
+	}
+	if len(ids) == 0 {
+		return DeleteStats{}, nil
+	}
+
+	stats, err := deleteTasks(ctx, tx, ids)
+	if err != nil {
+		return DeleteStats{}, err
+	}
+	return stats, finish(ctx, tx)
+}
+
+// deleteTasks удаляет в транзакции tx задачи с id из ids вместе со связями
+// с метками и записывает события об удалении и записи аудита. Связи удаляются до задач,
+// пока триггеры счётчиков меток ещё видят удаляемые задачи.
+func deleteTasks(ctx context.Context, tx pgx.Tx, ids []int) (DeleteStats, error) {
+	var stats DeleteStats
+	tag, err := tx.Exec(ctx, `DELETE FROM tasks_labels WHERE task_id = ANY($1);`, ids)
+	if err != nil {
+		return DeleteStats{}, err
+	}
+	stats.Labels = int(tag.RowsAffected())
+
+	rows, err := tx.Query(ctx, `
+		DELETE FROM tasks AS t
+		WHERE t.id = ANY($1)
+		RETURNING `+taskColumns+`;
+	`,
+		ids,
+	)
+	if err != nil {
+		return DeleteStats{}, err
+	}
+	deleted, err := scanTasks(rows, len(ids))
+	if err != nil {
+		return DeleteStats{}, err
+	}
+	for _, t := range deleted {
+		if err = addEvent(ctx, tx, EventTaskDeleted, t); err != nil {
+			return DeleteStats{}, err
+		}
+		err = addAudit(ctx, tx, AuditTaskDelete, target("task", t.ID), map[string]any{"title": t.Title})
+		if err != nil {
+			return DeleteStats{}, err
+		}
+	}
+	stats.Tasks = len(deleted)
+	return stats, nil
+}
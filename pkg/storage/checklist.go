@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrChecklistItemNotFound возвращается, когда пункт чек-листа
+// с указанным id отсутствует.
+var ErrChecklistItemNotFound = errors.New("checklist item not found")
+
+// Пункт чек-листа задачи.
+type ChecklistItem struct {
+	ID       int    `json:"id"`
+	TaskID   int    `json:"task_id"`
+	Text     string `json:"text"`
+	Done     bool   `json:"done"`
+	Position int    `json:"position"` // порядок в чек-листе
+}
+
+// Выполнение чек-листа задачи.
+type ChecklistProgress struct {
+	Total int // всего пунктов
+	Done  int // выполнено пунктов
+}
+
+// Percent возвращает долю выполненных пунктов в процентах,
+// для пустого чек-листа - 0.
+func (p ChecklistProgress) Percent() int {
+	if p.Total == 0 {
+		return 0
+	}
+	return p.Done * 100 / p.Total
+}
+
+// Столбцы пункта чек-листа в порядке полей ChecklistItem.
+const checklistColumns = `id, task_id, text, done, position`
+
+// scanChecklistItem сканирует строку со столбцами checklistColumns.
+func scanChecklistItem(row pgx.Row) (ChecklistItem, error) {
+	var c ChecklistItem
+	err := row.Scan(&c.ID, &c.TaskID, &c.Text, &c.Done, &c.Position)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ChecklistItem{}, ErrChecklistItemNotFound
+	}
+	return c, err
+}
+
+// AddChecklistItem добавляет в конец чек-листа задачи taskID
+// невыполненный пункт с текстом text и возвращает его.
+// Если задачи нет, возвращается ErrTaskNotFound.
+func (s *Storage) AddChecklistItem(ctx context.Context, taskID int, text string) (ChecklistItem, error) {
+	if err := s.checkWritable(); err != nil {
+		return ChecklistItem{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	c, err := scanChecklistItem(s.db.QueryRow(ctx, `
+		INSERT INTO checklist_items (task_id, text, position)
+		SELECT t.id, $2, (
+			SELECT coalesce(max(c.position) + 1, 0)
+			FROM checklist_items c WHERE c.task_id = t.id
+		)
+		FROM tasks t
+		WHERE t.id = $1
+		RETURNING `+checklistColumns+`;
+		`,
+		taskID,
+		text,
+	))
+	if errors.Is(err, ErrChecklistItemNotFound) {
+		return ChecklistItem{}, ErrTaskNotFound
+	}
+	return c, err
+}
+
+// ChecklistItems возвращает чек-лист задачи в порядке пунктов.
+func (s *Storage) ChecklistItems(ctx context.Context, taskID int) ([]ChecklistItem, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+checklistColumns+`
+		FROM checklist_items
+		WHERE task_id = $1
+		ORDER BY position, id;
+	`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChecklistItem
+	for rows.Next() {
+		c, err := scanChecklistItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+// UpdateChecklistItem сохраняет текст, отметку о выполнении и позицию
+// пункта c.ID и возвращает пункт.
+func (s *Storage) UpdateChecklistItem(ctx context.Context, c ChecklistItem) (ChecklistItem, error) {
+	if err := s.checkWritable(); err != nil {
+		return ChecklistItem{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return scanChecklistItem(s.db.QueryRow(ctx, `
+		UPDATE checklist_items
+		SET text = $1,
+			done = $2,
+			position = $3
+		WHERE id = $4
+		RETURNING `+checklistColumns+`;
+		`,
+		c.Text,
+		c.Done,
+		c.Position,
+		c.ID,
+	))
+}
+
+// DeleteChecklistItem удаляет пункт чек-листа.
+func (s *Storage) DeleteChecklistItem(ctx context.Context, id int) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tag, err := s.db.Exec(ctx, `DELETE FROM checklist_items WHERE id = $1;`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrChecklistItemNotFound
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// pool - пул соединений, помечающий каждый запрос идентификатором
+// запроса из контекста (см. WithRequestID).
+type pool struct {
+	*pgxpool.Pool
+}
+
+func (p pool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return p.Pool.Exec(ctx, annotate(ctx, sql), args...)
+}
+
+func (p pool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return p.Pool.Query(ctx, annotate(ctx, sql), args...)
+}
+
+func (p pool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return p.Pool.QueryRow(ctx, annotate(ctx, sql), args...)
+}
+
+func (p pool) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return annotatedTx{tx}, nil
+}
+
+// annotatedTx - транзакция, помечающая запросы так же, как pool.
+type annotatedTx struct {
+	pgx.Tx
+}
+
+func (tx annotatedTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return tx.Tx.Exec(ctx, annotate(ctx, sql), args...)
+}
+
+func (tx annotatedTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return tx.Tx.Query(ctx, annotate(ctx, sql), args...)
+}
+
+func (tx annotatedTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return tx.Tx.QueryRow(ctx, annotate(ctx, sql), args...)
+}
+
+func (tx annotatedTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	sp, err := tx.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return annotatedTx{sp}, nil
+}
+
+// annotate добавляет к запросу комментарий с идентификатором запроса,
+// чтобы запрос можно было найти в журнале медленных запросов БД.
+func annotate(ctx context.Context, sql string) string {
+	id := RequestID(ctx)
+	if id == "" {
+		return sql
+	}
+	return "/* request_id=" + id + " */ " + sql
+}
+
+// sanitizeRequestID оставляет в идентификаторе только символы,
+// безопасные внутри SQL-комментария.
+func sanitizeRequestID(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '-', r == '_', r == '.', r == ':':
+			return r
+		}
+		return -1
+	}, id)
+}
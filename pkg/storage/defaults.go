@@ -0,0 +1,49 @@
+package storage
+
+// Значения по умолчанию для полей, не заданных при создании задачи.
+// Нулевые значения полей Defaults ничего не подставляют.
+type Defaults struct {
+	Priority int      // приоритет задачи с нулевым приоритетом
+	Labels   []string // метки задачи, созданной без меток
+	// EpicLabels - метки задачи эпика, созданной без меток,
+	// по id эпика; для эпиков из списка заменяют Labels.
+	EpicLabels map[int][]string
+	// Ответственный за задачу, которой не назначен ответственный
+	// ни при создании, ни правилами назначения: автор задачи,
+	// если AssignAuthor, иначе пользователь Assignee.
+	AssignAuthor bool
+	Assignee     int
+}
+
+// WithDefaults задаёт значения по умолчанию, которые NewTask
+// и NewTaskWithLabels подставляют в незаданные поля задачи.
+func WithDefaults(d Defaults) Option {
+	return func(c *config) {
+		c.defaults = d
+	}
+}
+
+// apply подставляет приоритет и метки по умолчанию в незаданные поля
+// задачи t и возвращает задачу вместе с её метками.
+func (d Defaults) apply(t Task, labels []string) (Task, []string) {
+	if t.Priority == 0 {
+		t.Priority = d.Priority
+	}
+	if len(labels) == 0 {
+		if l, ok := d.EpicLabels[t.EpicID]; ok && t.EpicID != 0 {
+			labels = l
+		} else {
+			labels = d.Labels
+		}
+		labels = append([]string(nil), labels...)
+	}
+	return t, labels
+}
+
+// assignee возвращает ответственного по умолчанию за задачу t.
+func (d Defaults) assignee(t Task) int {
+	if d.AssignAuthor {
+		return t.AuthorID
+	}
+	return d.Assignee
+}
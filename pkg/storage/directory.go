@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrUserConflict возвращается ImportDirectory с сохранением id,
+// если id импортируемого пользователя занят пользователем
+// с другим адресом электронной почты.
+var ErrUserConflict = errors.New("user id belongs to another email")
+
+// Справочники экземпляра - пользователи и метки - в формате
+// ExportDirectory. Хэши паролей, роли, сессии и ключи API
+// не переносятся, чтобы стенд, скопированный с рабочего экземпляра,
+// не принимал его учётные данные.
+type Directory struct {
+	Users  []DirectoryUser  `json:"users"`
+	Labels []DirectoryLabel `json:"labels"`
+}
+
+// Пользователь в справочниках экземпляра.
+type DirectoryUser struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Метка в справочниках экземпляра.
+type DirectoryLabel struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Соответствие id справочников исходного экземпляра id после импорта,
+// для пересчёта ссылок в импортируемых затем задачах.
+type IDMap struct {
+	Users  map[int]int
+	Labels map[int]int
+}
+
+// ExportDirectory записывает в w пользователей и метки в формате JSON
+// для переноса экземпляра, например на тестовый стенд.
+func (s *Storage) ExportDirectory(ctx context.Context, w io.Writer) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var d Directory
+	rows, err := s.db.Query(ctx, `SELECT id, name, email FROM users ORDER BY id;`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var u DirectoryUser
+		if err = rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
+			rows.Close()
+			return err
+		}
+		d.Users = append(d.Users, u)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows, err = s.db.Query(ctx, `SELECT id, name FROM labels ORDER BY id;`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var l DirectoryLabel
+		if err = rows.Scan(&l.ID, &l.Name); err != nil {
+			rows.Close()
+			return err
+		}
+		d.Labels = append(d.Labels, l)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// ImportDirectory добавляет пользователей и метки из JSON в формате
+// ExportDirectory в одной транзакции и возвращает соответствие id.
+//
+// С preserveIDs записи сохраняют исходные id, существующие записи
+// с теми же id перезаписываются, а последовательности id сдвигаются
+// за наибольший импортированный; совпадение названия метки или адреса
+// с записью под другим id завершает импорт ошибкой. Пользователь
+// перезаписывается, только если адрес не меняется (без учёта регистра):
+// иначе новый человек получил бы пароль, сессии, ключи API и роль
+// прежнего, поэтому импорт завершается ошибкой ErrUserConflict.
+// Без preserveIDs пользователь сопоставляется с существующим по адресу
+// электронной почты, метка - по названию, остальные записи добавляются
+// с новыми id.
+func (s *Storage) ImportDirectory(ctx context.Context, r io.Reader, preserveIDs bool) (IDMap, error) {
+	if err := s.checkWritable(); err != nil {
+		return IDMap{}, err
+	}
+	var d Directory
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return IDMap{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return IDMap{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	m := IDMap{Users: map[int]int{0: 0}, Labels: make(map[int]int)}
+	for _, u := range d.Users {
+		// пользователь по умолчанию есть в каждом экземпляре
+		if u.ID == 0 {
+			continue
+		}
+		var id int
+		if preserveIDs {
+			err = tx.QueryRow(ctx, `
+				INSERT INTO users AS u (id, name, email) VALUES ($1, $2, $3)
+				ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, email = EXCLUDED.email
+				WHERE lower(u.email) = lower(EXCLUDED.email)
+				RETURNING id;
+			`,
+				u.ID,
+				u.Name,
+				u.Email,
+			).Scan(&id)
+			if errors.Is(err, pgx.ErrNoRows) {
+				err = ErrUserConflict
+			}
+		} else {
+			id, err = importUser(ctx, tx, u.Name, u.Email)
+		}
+		if err != nil {
+			return IDMap{}, err
+		}
+		m.Users[u.ID] = id
+	}
+	for _, l := range d.Labels {
+		var id int
+		if preserveIDs {
+			err = tx.QueryRow(ctx, `
+				INSERT INTO labels (id, name) VALUES ($1, $2)
+				ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name
+				RETURNING id;
+			`,
+				l.ID,
+				l.Name,
+			).Scan(&id)
+		} else {
+			err = tx.QueryRow(ctx, `
+				INSERT INTO labels (name) VALUES ($1)
+				ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+				RETURNING id;
+			`,
+				l.Name,
+			).Scan(&id)
+		}
+		if err != nil {
+			return IDMap{}, err
+		}
+		m.Labels[l.ID] = id
+	}
+	if preserveIDs {
+		for _, table := range []string{"users", "labels"} {
+			_, err = tx.Exec(ctx, `
+				SELECT setval(pg_get_serial_sequence('`+table+`', 'id'), greatest(max(id), 1))
+				FROM `+table+`;
+			`)
+			if err != nil {
+				return IDMap{}, err
+			}
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return IDMap{}, err
+	}
+	return m, nil
+}
+
+// importUser возвращает id пользователя с адресом email,
+// а если его нет или адрес пуст - добавляет пользователя.
+func importUser(ctx context.Context, tx pgx.Tx, name, email string) (int, error) {
+	var id int
+	if strings.TrimSpace(email) != "" {
+		err := tx.QueryRow(ctx, `
+			SELECT id FROM users WHERE lower(email) = lower($1) AND email <> '';
+		`,
+			email,
+		).Scan(&id)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return id, err
+		}
+	}
+	err := tx.QueryRow(ctx, `
+		INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id;
+	`,
+		name,
+		email,
+	).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// TasksDueSoon возвращает открытые задачи, срок которых наступает
+// в течение within от текущего момента, включая просроченные,
+// кроме отложенных, в порядке срока выполнения. Если assignee не 0, выбираются
+// только задачи этого ответственного.
+func (s *Storage) TasksDueSoon(ctx context.Context, within time.Duration, assignee int) ([]Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE
+			t.closed = 0 AND
+			t.due <> 0 AND
+			t.due <= $1 AND
+			($2 = 0 OR t.assigned_id = $2) AND
+			t.snoozed <= extract(epoch from now()) AND
+			NOT t.draft
+		ORDER BY t.due, t.id;
+	`,
+		time.Now().Add(within).Unix(),
+		assignee,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows, 0)
+}
+
+// Timeline возвращает задачи со сроком, интервал выполнения которых
+// (от создания до срока) пересекается с периодом [from, to],
+// в порядке начала и затем срока - для отображения на диаграмме Ганта.
+func (s *Storage) Timeline(ctx context.Context, from, to time.Time) ([]Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE
+			t.due <> 0 AND
+			t.opened <= $2 AND
+			t.due >= $1 AND
+			NOT t.draft
+		ORDER BY t.opened, t.due, t.id;
+	`,
+		from.Unix(),
+		to.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows, 0)
+}
+
+// SetUserTimeZone задаёт часовой пояс пользователя по имени IANA,
+// например "Europe/Moscow".
+func (s *Storage) SetUserTimeZone(ctx context.Context, userID int, tz string) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tag, err := s.db.Exec(ctx, `UPDATE users SET time_zone = $2 WHERE id = $1;`, userID, tz)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// userLocation возвращает часовой пояс вызова: из контекста (WithLocation),
+// иначе пояс пользователя userID, иначе UTC.
+func (s *Storage) userLocation(ctx context.Context, userID int) (*time.Location, error) {
+	if loc := Location(ctx); loc != nil {
+		return loc, nil
+	}
+	if userID == 0 {
+		return time.UTC, nil
+	}
+	var tz string
+	err := s.db.QueryRow(ctx, `SELECT time_zone FROM users WHERE id = $1;`, userID).Scan(&tz)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.UTC, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// TasksDueToday возвращает открытые задачи, срок которых приходится
+// на текущий день в часовом поясе вызова (см. userLocation), в порядке
+// срока. Если assignee не 0, выбираются только задачи этого
+// ответственного, а без пояса в контексте используется его пояс.
+func (s *Storage) TasksDueToday(ctx context.Context, assignee int) ([]Task, error) {
+	return s.tasksDueByDay(ctx, assignee, true)
+}
+
+// TasksOverdue возвращает открытые задачи, день срока которых в часовом
+// поясе вызова уже прошёл, в порядке срока. Параметр assignee - как
+// в TasksDueToday.
+func (s *Storage) TasksOverdue(ctx context.Context, assignee int) ([]Task, error) {
+	return s.tasksDueByDay(ctx, assignee, false)
+}
+
+// tasksDueByDay возвращает открытые задачи со сроком в текущий день
+// часового пояса вызова, если today, иначе - со сроком до его начала.
+func (s *Storage) tasksDueByDay(ctx context.Context, assignee int, today bool) ([]Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	loc, err := s.userLocation(ctx, assignee)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().In(loc)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	// нулевой срок означает задачу без срока
+	from, to := int64(1), start.Unix()
+	if today {
+		from, to = start.Unix(), start.AddDate(0, 0, 1).Unix()
+	}
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE
+			t.closed = 0 AND
+			t.due >= $1 AND
+			t.due < $2 AND
+			($3 = 0 OR t.assigned_id = $3) AND
+			t.snoozed <= extract(epoch from now()) AND
+			NOT t.draft
+		ORDER BY t.due, t.id;
+	`,
+		from,
+		to,
+		assignee,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows, 0)
+}
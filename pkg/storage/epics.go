@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrEpicNotFound возвращается, когда эпик с указанным id отсутствует.
+var ErrEpicNotFound = errors.New("epic not found")
+
+// Эпик - крупная цель, объединяющая несколько задач.
+type Epic struct {
+	ID          int
+	Opened      int64
+	Closed      int64
+	Title       string
+	Description string
+}
+
+// Прогресс выполнения эпика.
+type EpicProgress struct {
+	Total       int // всего задач
+	Done        int // выполнено задач
+	TotalPoints int // сумма оценок всех задач
+	DonePoints  int // сумма оценок выполненных задач
+}
+
+// Столбцы эпика в порядке полей Epic.
+const epicColumns = `id, opened, closed, title, description`
+
+// scanEpic сканирует строку со столбцами epicColumns.
+func scanEpic(row pgx.Row) (Epic, error) {
+	var e Epic
+	err := row.Scan(&e.ID, &e.Opened, &e.Closed, &e.Title, &e.Description)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Epic{}, ErrEpicNotFound
+	}
+	return e, err
+}
+
+// NewEpic создаёт эпик и возвращает его.
+func (s *Storage) NewEpic(ctx context.Context, e Epic) (Epic, error) {
+	if err := s.checkWritable(); err != nil {
+		return Epic{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return scanEpic(s.db.QueryRow(ctx, `
+		INSERT INTO epics (title, description)
+		VALUES ($1, $2)
+		RETURNING `+epicColumns+`;
+		`,
+		e.Title,
+		e.Description,
+	))
+}
+
+// EpicByID возвращает эпик по id.
+func (s *Storage) EpicByID(ctx context.Context, id int) (Epic, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return scanEpic(s.db.QueryRow(ctx, `
+		SELECT `+epicColumns+` FROM epics WHERE id = $1;
+		`,
+		id,
+	))
+}
+
+// Epics возвращает список всех эпиков.
+func (s *Storage) Epics(ctx context.Context) ([]Epic, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+epicColumns+` FROM epics ORDER BY id;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var epics []Epic
+	for rows.Next() {
+		e, err := scanEpic(rows)
+		if err != nil {
+			return nil, err
+		}
+		epics = append(epics, e)
+	}
+	return epics, rows.Err()
+}
+
+// UpdateEpic обновляет название, описание и время завершения эпика.
+func (s *Storage) UpdateEpic(ctx context.Context, e Epic) (Epic, error) {
+	if err := s.checkWritable(); err != nil {
+		return Epic{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return scanEpic(s.db.QueryRow(ctx, `
+		UPDATE epics
+		SET title = $1,
+			description = $2,
+			closed = $3
+		WHERE id = $4
+		RETURNING `+epicColumns+`;
+		`,
+		e.Title,
+		e.Description,
+		e.Closed,
+		e.ID,
+	))
+}
+
+// DeleteEpic удаляет эпик. Задачи эпика остаются без эпика.
+func (s *Storage) DeleteEpic(ctx context.Context, id int) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tag, err := s.db.Exec(ctx, `DELETE FROM epics WHERE id = $1;`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEpicNotFound
+	}
+	return nil
+}
+
+// EpicProgress возвращает число выполненных задач эпика
+// и сумму их оценок относительно всех задач эпика.
+func (s *Storage) EpicProgress(ctx context.Context, id int) (EpicProgress, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var p EpicProgress
+	err := s.db.QueryRow(ctx, `
+		SELECT
+			count(*),
+			count(*) FILTER (WHERE coalesce(closed, 0) <> 0),
+			coalesce(sum(estimate), 0),
+			coalesce(sum(estimate) FILTER (WHERE coalesce(closed, 0) <> 0), 0)
+		FROM tasks
+		WHERE epic_id = $1 AND NOT draft;
+		`,
+		id,
+	).Scan(&p.Total, &p.Done, &p.TotalPoints, &p.DonePoints)
+	return p, err
+}
+
+// TasksByEpic возвращает задачи эпика.
+func (s *Storage) TasksByEpic(ctx context.Context, epicID int) ([]Task, error) {
+	return s.TasksByFilter(ctx, TaskFilter{EpicID: epicID, Snoozed: true})
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"strings"
+)
+
+// DebugPlan выполняет запрос TasksByFilter для фильтра f под
+// EXPLAIN (ANALYZE, BUFFERS) и возвращает план выполнения в текстовом
+// виде - для поиска недостающих индексов.
+// Запрос действительно выполняется, поэтому метод не стоит вызывать
+// для тяжёлых фильтров на нагруженной БД.
+func (s *Storage) DebugPlan(ctx context.Context, f TaskFilter) (string, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	sql, args := f.query()
+	rows, err := s.db.Query(ctx, `EXPLAIN (ANALYZE, BUFFERS) `+sql, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err = rows.Scan(&line); err != nil {
+			return "", err
+		}
+		plan = append(plan, line)
+	}
+	return strings.Join(plan, "\n"), rows.Err()
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Порядок сортировки списка задач.
+type Order int
+
+const (
+	// OrderID - по возрастанию id, порядок по умолчанию.
+	OrderID Order = iota
+	// OrderTriage - порядок разбора: только открытые задачи,
+	// сначала с большим приоритетом, затем с ближайшим сроком,
+	// затем более старые.
+	OrderTriage
+	// OrderUpdated - сначала недавно изменённые задачи.
+	OrderUpdated
+)
+
+// orderBy возвращает выражение ORDER BY для порядка сортировки.
+func (o Order) orderBy() string {
+	switch o {
+	case OrderTriage:
+		return "t.priority DESC, nullif(t.due, 0) ASC NULLS LAST, t.opened, t.id"
+	case OrderUpdated:
+		return "t.updated DESC, t.id DESC"
+	default:
+		return "t.id"
+	}
+}
+
+// Фильтр для выборки списка задач.
+// Поля с нулевыми значениями выборку не ограничивают.
+type TaskFilter struct {
+	AuthorID   int    // автор задачи
+	AssignedID int    // ответственный
+	Label      string // название метки
+	EpicID     int    // эпик
+	Drafts     bool   // включать черновики
+	Snoozed    bool   // включать отложенные открытые задачи
+	Order      Order  // порядок сортировки
+	// StarredBy - пользователь, отмеченные которым задачи идут
+	// в списке первыми, а внутри каждой части действует Order;
+	// 0 - без учёта отметок.
+	StarredBy int
+
+	// Периоды создания, выполнения и изменения задачи в секундах Unix:
+	// нижняя граница включается, верхняя - нет.
+	// Границы выполнения выбирают только выполненные задачи.
+	OpenedAfter  int64
+	OpenedBefore int64
+	ClosedAfter  int64
+	ClosedBefore int64
+	UpdatedAfter int64
+
+	// Постраничная выборка. AfterID - курсор: id последней задачи
+	// предыдущей страницы, применим только при порядке OrderID
+	// без StarredBy.
+	// Для остальных порядков используется смещение Offset.
+	// Limit 0 означает выборку без ограничения.
+	Limit   int
+	Offset  int
+	AfterID int
+}
+
+// where возвращает условие WHERE для фильтра, дописывая его параметры к args.
+// Столбцы задачи в запросе должны быть доступны через псевдоним t.
+func (f TaskFilter) where(args []any) (string, []any) {
+	conds := []string{"TRUE"}
+	if !f.Drafts {
+		conds = append(conds, "NOT t.draft")
+	}
+	if !f.Snoozed {
+		// выполненные задачи не скрываются, даже если были отложены
+		conds = append(conds, "(t.closed <> 0 OR t.snoozed <= extract(epoch from now()))")
+	}
+	// add заменяет ? в условии на номер очередного параметра
+	add := func(cond string, v any) {
+		args = append(args, v)
+		conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if f.Order == OrderTriage {
+		conds = append(conds, "t.closed = 0")
+	}
+	if f.AuthorID != 0 {
+		add("t.author_id = ?", f.AuthorID)
+	}
+	if f.AssignedID != 0 {
+		add("t.assigned_id = ?", f.AssignedID)
+	}
+	if f.AfterID != 0 {
+		add("t.id > ?", f.AfterID)
+	}
+	if f.OpenedAfter != 0 {
+		add("t.opened >= ?", f.OpenedAfter)
+	}
+	if f.OpenedBefore != 0 {
+		add("t.opened < ?", f.OpenedBefore)
+	}
+	if f.ClosedAfter != 0 {
+		add("t.closed >= ?", f.ClosedAfter)
+	}
+	if f.ClosedBefore != 0 {
+		add("t.closed <> 0 AND t.closed < ?", f.ClosedBefore)
+	}
+	if f.UpdatedAfter != 0 {
+		add("t.updated >= ?", f.UpdatedAfter)
+	}
+	if f.EpicID != 0 {
+		add("t.epic_id = ?", f.EpicID)
+	}
+	if f.Label != "" {
+		add(`t.id IN (
+			SELECT tl.task_id FROM tasks_labels tl
+			JOIN labels l ON l.id = tl.label_id
+			WHERE l.name = ?)`, f.Label)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// orderBy возвращает выражение ORDER BY для фильтра, дописывая
+// его параметры к args.
+func (f TaskFilter) orderBy(args []any) (string, []any) {
+	if f.StarredBy == 0 {
+		return f.Order.orderBy(), args
+	}
+	args = append(args, f.StarredBy)
+	return `t.id IN (
+		SELECT ts.task_id FROM task_stars ts
+		WHERE ts.user_id = $` + strconv.Itoa(len(args)) + `) DESC, ` + f.Order.orderBy(), args
+}
+
+// query возвращает запрос списка задач по фильтру и его параметры.
+func (f TaskFilter) query() (string, []any) {
+	where, args := f.where(nil)
+	order, args := f.orderBy(args)
+	return `
+		SELECT ` + taskColumns + `
+		FROM tasks t
+		WHERE ` + where + `
+		ORDER BY ` + order + f.limit() + `;
+	`, args
+}
+
+// limit возвращает предложения LIMIT и OFFSET для постраничной выборки.
+func (f TaskFilter) limit() string {
+	var sb strings.Builder
+	if f.Limit > 0 {
+		sb.WriteString(" LIMIT " + strconv.Itoa(f.Limit))
+	}
+	if f.Offset > 0 {
+		sb.WriteString(" OFFSET " + strconv.Itoa(f.Offset))
+	}
+	return sb.String()
+}
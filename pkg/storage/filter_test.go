@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTaskFilterWhere(t *testing.T) {
+	// условия по умолчанию скрывают черновики и отложенные задачи
+	const hidden = "TRUE AND NOT t.draft AND (t.closed <> 0 OR t.snoozed <= extract(epoch from now()))"
+	tests := []struct {
+		name  string
+		f     TaskFilter
+		where string
+		args  []any
+	}{
+		{"empty", TaskFilter{}, hidden, nil},
+		{"drafts and snoozed", TaskFilter{Drafts: true, Snoozed: true}, "TRUE", nil},
+		{"triage", TaskFilter{Drafts: true, Snoozed: true, Order: OrderTriage}, "TRUE AND t.closed = 0", nil},
+		{
+			"author and assignee",
+			TaskFilter{AuthorID: 1, AssignedID: 2},
+			hidden + " AND t.author_id = $1 AND t.assigned_id = $2",
+			[]any{1, 2},
+		},
+		{
+			"cursor and epic",
+			TaskFilter{Drafts: true, Snoozed: true, AfterID: 10, EpicID: 3},
+			"TRUE AND t.id > $1 AND t.epic_id = $2",
+			[]any{10, 3},
+		},
+		{
+			"periods",
+			TaskFilter{Drafts: true, Snoozed: true, OpenedAfter: 1, OpenedBefore: 2, ClosedAfter: 3, ClosedBefore: 4, UpdatedAfter: 5},
+			"TRUE AND t.opened >= $1 AND t.opened < $2 AND t.closed >= $3 AND t.closed <> 0 AND t.closed < $4 AND t.updated >= $5",
+			[]any{int64(1), int64(2), int64(3), int64(4), int64(5)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := tt.f.where(nil)
+			if where != tt.where {
+				t.Errorf("where = %q, want %q", where, tt.where)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestTaskFilterWhereArgs(t *testing.T) {
+	// параметры фильтра нумеруются после уже переданных
+	f := TaskFilter{Drafts: true, Snoozed: true, AuthorID: 7, Label: "bug"}
+	where, args := f.where([]any{"x"})
+	if !strings.Contains(where, "t.author_id = $2") || !strings.Contains(where, "l.name = $3") {
+		t.Errorf("where = %q", where)
+	}
+	if !reflect.DeepEqual(args, []any{"x", 7, "bug"}) {
+		t.Errorf("args = %v", args)
+	}
+}
+
+func TestTaskFilterQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		f     TaskFilter
+		order string
+		args  []any
+	}{
+		{"default", TaskFilter{}, "ORDER BY t.id;", nil},
+		{"updated", TaskFilter{Order: OrderUpdated}, "ORDER BY t.updated DESC, t.id DESC;", nil},
+		{"triage", TaskFilter{Order: OrderTriage}, "ORDER BY t.priority DESC, nullif(t.due, 0) ASC NULLS LAST, t.opened, t.id;", nil},
+		{"limit", TaskFilter{Limit: 10}, "ORDER BY t.id LIMIT 10;", nil},
+		{"limit and offset", TaskFilter{Limit: 10, Offset: 20}, "ORDER BY t.id LIMIT 10 OFFSET 20;", nil},
+		{"offset only", TaskFilter{Offset: 20}, "ORDER BY t.id OFFSET 20;", nil},
+		{
+			"starred after filter args",
+			TaskFilter{AuthorID: 1, StarredBy: 5, Limit: 3},
+			"WHERE ts.user_id = $2) DESC, t.id LIMIT 3;",
+			[]any{1, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, args := tt.f.query()
+			if !strings.HasSuffix(strings.TrimSpace(q), tt.order) {
+				t.Errorf("query = %q, want suffix %q", q, tt.order)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+)
+
+// TasksFingerprint возвращает отпечаток списка задач по фильтру f,
+// меняющийся при создании, изменении и удалении подходящих задач:
+// число задач, наибольшее время изменения и наибольший id.
+// Отпечаток дешевле самой выборки и годится для ETag списка.
+// Постраничные поля фильтра, кроме AfterID, не учитываются: отпечаток
+// общий для всех страниц. Время изменения хранится в секундах, поэтому
+// повторное изменение задачи в ту же секунду отпечаток не меняет,
+// как и изменение меток задачи.
+func (s *Storage) TasksFingerprint(ctx context.Context, f TaskFilter) (string, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	where, args := f.where(nil)
+	var count, maxID int64
+	var maxUpdated int64
+	err := s.db.QueryRow(ctx, `
+		SELECT count(*), coalesce(max(t.updated), 0), coalesce(max(t.id), 0)
+		FROM tasks t
+		WHERE `+where+`;
+	`,
+		args...,
+	).Scan(&count, &maxUpdated, &maxID)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(count, 36) + "-" +
+		strconv.FormatInt(maxUpdated, 36) + "-" +
+		strconv.FormatInt(maxID, 36), nil
+}
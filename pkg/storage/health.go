@@ -0,0 +1,16 @@
+package storage
+
+import "context"
+
+// Ping проверяет доступность БД, выполняя простой запрос
+// на соединении из пула.
+func (s *Storage) Ping(ctx context.Context) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.db.Ping(ctx)
+}
+
+// Close закрывает пул соединений, дожидаясь возврата занятых соединений.
+func (s *Storage) Close() {
+	s.db.Close()
+}
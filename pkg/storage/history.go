@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrEventNotFound возвращается, когда событие отсутствует,
+// относится к другой задаче или не содержит состояния задачи.
+var ErrEventNotFound = errors.New("event not found")
+
+// Изменение поля задачи между двумя событиями.
+type FieldChange struct {
+	Field string // имя поля в JSON-представлении задачи
+	Old   any
+	New   any
+}
+
+// TaskEvents возвращает историю изменений задачи из outbox
+// в порядке их возникновения. События EventTaskLabels содержат
+// не состояние задачи, а TaskLabels, и в TaskDiff не сравниваются.
+func (s *Storage) TaskEvents(ctx context.Context, taskID int) ([]Event, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT id, created, type, task_id, payload
+		FROM outbox
+		WHERE task_id = $1
+		ORDER BY id;
+	`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []Event
+	for rows.Next() {
+		var e Event
+		err = rows.Scan(&e.ID, &e.Created, &e.Type, &e.TaskID, &e.Payload)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// TaskDiff сравнивает состояния задачи, зафиксированные событиями
+// fromEvent и toEvent, и возвращает изменившиеся поля по алфавиту.
+// Для события без состояния задачи, например EventTaskLabels,
+// возвращается ErrEventNotFound.
+func (s *Storage) TaskDiff(ctx context.Context, taskID int, fromEvent, toEvent int64) ([]FieldChange, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	from, err := s.eventPayload(ctx, taskID, fromEvent)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.eventPayload(ctx, taskID, toEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool)
+	for f := range from {
+		fields[f] = true
+	}
+	for f := range to {
+		fields[f] = true
+	}
+	var changes []FieldChange
+	for f := range fields {
+		if !reflect.DeepEqual(from[f], to[f]) {
+			changes = append(changes, FieldChange{Field: f, Old: from[f], New: to[f]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// типы событий, содержимое которых - состояние задачи
+var snapshotEvents = []string{EventTaskCreated, EventTaskUpdated, EventTaskClosed, EventTaskDeleted}
+
+// eventPayload возвращает состояние задачи из события outbox.
+func (s *Storage) eventPayload(ctx context.Context, taskID int, eventID int64) (map[string]any, error) {
+	var payload []byte
+	err := s.db.QueryRow(ctx, `
+		SELECT payload FROM outbox WHERE id = $1 AND task_id = $2 AND type = ANY($3);
+		`,
+		eventID,
+		taskID,
+		snapshotEvents,
+	).Scan(&payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	err = json.Unmarshal(payload, &m)
+	return m, err
+}
+
+// EventsAfter возвращает до limit событий всех задач, следующих в ленте
+// за событием afterID (0 - с начала ленты), - для потоковой доставки
+// клиентам с возобновлением.
+//
+// Id событий выделяются последовательностью до фиксации транзакции,
+// поэтому событие долгой транзакции может стать видимым позже события
+// с большим id. Лента упорядочена по транзакции, записавшей событие,
+// а затем по id, и содержит только события транзакций старше самой
+// старой из ещё выполняющихся (xmin снимка): их набор уже не меняется,
+// а события остальных транзакций окажутся в ленте после них. Поэтому
+// получатель, продолжающий чтение с id последнего полученного события,
+// ничего не пропускает, но видит новые события только после завершения
+// всех начавшихся раньше транзакций БД, в том числе долгих.
+// Id событий в ленте не обязательно возрастают.
+func (s *Storage) EventsAfter(ctx context.Context, afterID int64, limit int) ([]Event, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT o.id, o.created, o.type, o.task_id, o.payload
+		FROM outbox o
+		WHERE o.xid < pg_snapshot_xmin(pg_current_snapshot())
+			AND ($1::bigint = 0 OR (o.xid, o.id) > (
+				SELECT a.xid, a.id FROM outbox a WHERE a.id = $1
+			))
+		ORDER BY o.xid, o.id
+		LIMIT $2;
+	`,
+		afterID,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []Event
+	for rows.Next() {
+		var e Event
+		err = rows.Scan(&e.ID, &e.Created, &e.Type, &e.TaskID, &e.Payload)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// LastEventID возвращает id последнего события ленты EventsAfter
+// или 0, если событий нет.
+func (s *Storage) LastEventID(ctx context.Context) (int64, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var id int64
+	err := s.db.QueryRow(ctx, `
+		SELECT id
+		FROM outbox
+		WHERE xid < pg_snapshot_xmin(pg_current_snapshot())
+		ORDER BY xid DESC, id DESC
+		LIMIT 1;
+	`).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// UserByIdentity возвращает пользователя, связанного с внешней учётной
+// записью subject поставщика provider. При первом входе пользователь
+// создаётся по профилю profile. Адрес электронной почты служит логином
+// для входа по паролю, поэтому в profile.Email передаётся только адрес,
+// подтверждённый поставщиком. Адрес сохраняется, только если он не занят:
+// существующие пользователи по адресу не связываются.
+// Вход записывается в журнал аудита.
+func (s *Storage) UserByIdentity(ctx context.Context, provider, subject string, profile User) (User, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	u, err := s.identityUser(ctx, provider, subject)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		if err != nil {
+			return User{}, err
+		}
+		return u, s.auditIdentityLogin(ctx, u, provider)
+	}
+	if err = s.checkWritable(); err != nil {
+		return User{}, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	defer tx.Rollback(ctx)
+	err = tx.QueryRow(ctx, `
+		INSERT INTO users (name, email)
+		SELECT $1, CASE WHEN EXISTS (
+			SELECT 1 FROM users WHERE lower(email) = lower($2) AND email <> ''
+		) THEN '' ELSE $2 END
+		RETURNING id, name, email;
+	`,
+		profile.Name,
+		profile.Email,
+	).Scan(&u.ID, &u.Name, &u.Email)
+	if err != nil {
+		return User{}, err
+	}
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO user_identities (provider, subject, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING;
+	`,
+		provider,
+		subject,
+		u.ID,
+	)
+	if err != nil {
+		return User{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		// параллельный первый вход уже создал пользователя
+		tx.Rollback(ctx)
+		if u, err = s.identityUser(ctx, provider, subject); err != nil {
+			return User{}, err
+		}
+		return u, s.auditIdentityLogin(ctx, u, provider)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return User{}, err
+	}
+	return u, s.auditIdentityLogin(ctx, u, provider)
+}
+
+// auditIdentityLogin записывает вход через внешнего поставщика
+// в журнал аудита.
+func (s *Storage) auditIdentityLogin(ctx context.Context, u User, provider string) error {
+	if s.cfg.readOnly {
+		return nil
+	}
+	return addAudit(WithActor(ctx, u.ID), s.db, AuditLogin, target("user", u.ID), map[string]any{"method": provider})
+}
+
+// identityUser возвращает пользователя по внешней учётной записи
+// или pgx.ErrNoRows.
+func (s *Storage) identityUser(ctx context.Context, provider, subject string) (User, error) {
+	var u User
+	err := s.db.QueryRow(ctx, `
+		SELECT u.id, u.name, u.email
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2;
+	`,
+		provider,
+		subject,
+	).Scan(&u.ID, &u.Name, &u.Email)
+	return u, err
+}
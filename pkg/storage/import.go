@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Число строк CSV, добавляемых в одной транзакции.
+const importBatchSize = 500
+
+// Ошибка в строке импортируемого файла.
+type RowError struct {
+	Line int // номер строки файла, начиная с 1 (строка заголовка)
+	Err  error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// Импортируемая строка CSV.
+type importRow struct {
+	line   int
+	item   int // индекс результата строки
+	task   Task
+	labels []string
+}
+
+// ImportTasksCSV добавляет задачи из CSV с заголовком. Распознаются
+// столбцы title (обязательный), content, author_id, assigned_id,
+// epic_id, priority, estimate, opened, due и labels (метки через ";").
+// Даты задаются в формате 2006-01-02 или RFC 3339.
+//
+// Каждая строка проверяется отдельно; корректные строки добавляются
+// пачками по importBatchSize в одной транзакции, а ошибки проверки
+// и добавления не прерывают импорт. Результат содержит по элементу
+// на каждую строку данных в порядке файла: ID - id добавленной задачи,
+// Err - RowError с номером строки.
+// Ошибка возвращается, только если файл не удалось прочитать
+// или БД недоступна, а в режиме WithAllOrNothing - также ErrBatchAborted,
+// если хотя бы одна строка содержит ошибку; тогда ничего не добавляется.
+func (s *Storage) ImportTasksCSV(ctx context.Context, r io.Reader) (BatchResult, error) {
+	if err := s.checkWritable(); err != nil {
+		return BatchResult{}, err
+	}
+	var results []ItemResult
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return BatchResult{}, err
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := cols["title"]; !ok {
+		return BatchResult{}, errors.New("csv: title column is required")
+	}
+	users, err := s.userIDs(ctx)
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	var batch []importRow
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			results = append(results, ItemResult{Err: RowError{Line: line, Err: err}})
+			continue
+		}
+		if err != nil {
+			return newBatchResult(results), err
+		}
+		results = append(results, ItemResult{})
+		row, err := parseImportRow(cols, rec, users)
+		if err != nil {
+			results[len(results)-1].Err = RowError{Line: line, Err: err}
+			continue
+		}
+		row.line = line
+		row.item = len(results) - 1
+		batch = append(batch, row)
+		// в режиме "всё или ничего" все строки добавляются одной пачкой
+		if len(batch) == importBatchSize && !IsAllOrNothing(ctx) {
+			if err = s.importBatch(ctx, batch, results); err != nil {
+				return newBatchResult(results), err
+			}
+			batch = batch[:0]
+		}
+	}
+	if IsAllOrNothing(ctx) && newBatchResult(results).Failed > 0 {
+		return newBatchResult(results), ErrBatchAborted
+	}
+	err = s.importBatch(ctx, batch, results)
+	return newBatchResult(results), err
+}
+
+// importBatch добавляет пачку строк в одной транзакции и записывает
+// их результаты в results. Каждая строка добавляется в своей точке
+// сохранения, так что ошибка данных строки откатывает только её;
+// прочие ошибки БД прерывают импорт.
+func (s *Storage) importBatch(ctx context.Context, batch []importRow, results []ItemResult) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, row := range batch {
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		t, err := s.insertTask(ctx, sp, row.task)
+		if err == nil {
+			err = linkLabels(ctx, sp, t.ID, row.labels)
+		}
+		if err != nil && !isItemError(err) {
+			return err
+		}
+		if err != nil {
+			results[row.item].Err = RowError{Line: row.line, Err: err}
+			if err = sp.Rollback(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = sp.Commit(ctx); err != nil {
+			return err
+		}
+		results[row.item].ID = t.ID
+	}
+	if IsAllOrNothing(ctx) && newBatchResult(results).Failed > 0 {
+		return ErrBatchAborted
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		// пачка не добавлена целиком
+		for _, row := range batch {
+			results[row.item] = ItemResult{Err: RowError{Line: row.line, Err: err}}
+		}
+	}
+	return err
+}
+
+// parseImportRow проверяет строку CSV и преобразует её в задачу.
+func parseImportRow(cols map[string]int, rec []string, users map[int]bool) (importRow, error) {
+	field := func(name string) string {
+		if i, ok := cols[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	var row importRow
+	row.task.Title = field("title")
+	row.task.Content = field("content")
+	if err := row.task.validate(); err != nil {
+		return row, err
+	}
+
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"author_id", &row.task.AuthorID},
+		{"assigned_id", &row.task.AssignedID},
+		{"epic_id", &row.task.EpicID},
+		{"priority", &row.task.Priority},
+		{"estimate", &row.task.Estimate},
+	}
+	for _, c := range ints {
+		v := field(c.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return row, fmt.Errorf("%s: %w", c.name, err)
+		}
+		*c.dst = n
+	}
+	for _, id := range []int{row.task.AuthorID, row.task.AssignedID} {
+		if !users[id] {
+			return row, fmt.Errorf("unknown user %d", id)
+		}
+	}
+
+	dates := []struct {
+		name string
+		dst  *int64
+	}{
+		{"opened", &row.task.Opened},
+		{"due", &row.task.Due},
+	}
+	for _, c := range dates {
+		v := field(c.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339, v)
+		}
+		if err != nil {
+			return row, fmt.Errorf("%s: invalid date %q", c.name, v)
+		}
+		*c.dst = t.Unix()
+	}
+
+	for _, l := range strings.Split(field("labels"), ";") {
+		if l = strings.TrimSpace(l); l != "" {
+			row.labels = append(row.labels, l)
+		}
+	}
+	return row, nil
+}
+
+// userIDs возвращает множество id существующих пользователей.
+func (s *Storage) userIDs(ctx context.Context) (map[int]bool, error) {
+	rows, err := s.db.Query(ctx, `SELECT id FROM users;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
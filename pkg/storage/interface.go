@@ -0,0 +1,25 @@
+package storage
+
+import "context"
+
+// Interface - основные операции с задачами.
+// Позволяет оборачивать хранилище декораторами (кэш, метрики и т.п.).
+type Interface interface {
+	Tasks(ctx context.Context, taskID, authorID int) ([]Task, error)
+	TaskByID(ctx context.Context, id int) (Task, error)
+	TasksByFilter(ctx context.Context, f TaskFilter) ([]Task, error)
+	TaskByAuthor(ctx context.Context, authorID int) ([]Task, error)
+	TaskByLabel(ctx context.Context, labelName string) ([]Task, error)
+	TaskLabels(ctx context.Context, taskID int) ([]Label, error)
+
+	NewTask(ctx context.Context, t Task) (Task, error)
+	NewTaskWithLabels(ctx context.Context, t Task, labels []string) (Task, error)
+	UpdateTask(ctx context.Context, t Task) (Task, error)
+	DeleteTask(ctx context.Context, id int) (DeleteStats, error)
+	CloseTasks(ctx context.Context, ids []int) (BatchResult, error)
+	ReassignTasks(ctx context.Context, ids []int, newAssignee int) (BatchResult, error)
+	AddLabelToTasks(ctx context.Context, labelName string, taskIDs []int) (BatchResult, error)
+	RemoveLabelFromTasks(ctx context.Context, labelName string, taskIDs []int) (BatchResult, error)
+}
+
+var _ Interface = (*Storage)(nil)
@@ -0,0 +1,330 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrJobNotFound возвращается, когда задания с указанным id нет.
+var ErrJobNotFound = errors.New("job not found")
+
+// Состояние фонового задания.
+type JobStatus string
+
+const (
+	JobQueued  JobStatus = "queued"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// Фоновое задание.
+type Job struct {
+	ID       int64           `json:"id"`
+	Kind     string          `json:"kind"`
+	Params   json.RawMessage `json:"params"`
+	UserID   int             `json:"user_id"`
+	Status   JobStatus       `json:"status"`
+	Done     int             `json:"done"`  // выполнено единиц работы
+	Total    int             `json:"total"` // всего единиц работы, 0 - неизвестно
+	Error    string          `json:"error,omitempty"`
+	Attempts int             `json:"attempts"`
+	Created  int64           `json:"created"`
+	Finished int64           `json:"finished"`
+}
+
+// Результат фонового задания.
+type JobResult struct {
+	ContentType string
+	Data        []byte
+}
+
+// JobHandler выполняет задание с параметрами params, сообщая о ходе
+// работы через progress, и возвращает результат. Обработчик должен
+// завершаться при отмене ctx.
+type JobHandler func(ctx context.Context, params json.RawMessage, progress func(done, total int)) (JobResult, error)
+
+// Столбцы задания в порядке полей Job.
+const jobColumns = `id, kind, params, user_id, status, done, total, error, attempts, created, finished`
+
+// scanJob сканирует строку со столбцами jobColumns.
+func scanJob(row pgx.Row) (Job, error) {
+	var j Job
+	var status string
+	err := row.Scan(&j.ID, &j.Kind, &j.Params, &j.UserID, &status,
+		&j.Done, &j.Total, &j.Error, &j.Attempts, &j.Created, &j.Finished)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Job{}, ErrJobNotFound
+	}
+	j.Status = JobStatus(status)
+	return j, err
+}
+
+// EnqueueJob ставит в очередь задание вида kind с параметрами params
+// от имени пользователя из контекста (см. WithActor) и возвращает его.
+func (s *Storage) EnqueueJob(ctx context.Context, kind string, params any) (Job, error) {
+	if err := s.checkWritable(); err != nil {
+		return Job{}, err
+	}
+	if params == nil {
+		params = struct{}{}
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return Job{}, err
+	}
+	userID := Actor(ctx)
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return scanJob(s.db.QueryRow(ctx, `
+		INSERT INTO jobs (kind, params, user_id)
+		VALUES ($1, $2, $3)
+		RETURNING `+jobColumns+`;
+	`,
+		kind,
+		b,
+		userID,
+	))
+}
+
+// JobByID возвращает задание по id.
+func (s *Storage) JobByID(ctx context.Context, id int64) (Job, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return scanJob(s.db.QueryRow(ctx, `
+		SELECT `+jobColumns+` FROM jobs WHERE id = $1;
+	`,
+		id,
+	))
+}
+
+// JobResult возвращает результат выполненного задания.
+// Для невыполненного задания возвращается ErrJobNotFound.
+func (s *Storage) JobResult(ctx context.Context, id int64) (JobResult, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var r JobResult
+	err := s.db.QueryRow(ctx, `
+		SELECT result_type, coalesce(result, '')
+		FROM jobs
+		WHERE id = $1 AND status = 'done';
+	`,
+		id,
+	).Scan(&r.ContentType, &r.Data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return JobResult{}, ErrJobNotFound
+	}
+	return r, err
+}
+
+// Наибольшее число попыток выполнения задания: задание, зависшее
+// на последней попытке (например, обработчик роняет процесс),
+// помечается ошибочным, а не выполняется снова.
+const maxJobAttempts = 3
+
+// RunJobs выполняет задания видов из handlers по одному, пока не отменён
+// ctx; при пустой очереди она опрашивается с интервалом poll. Задания
+// разбираются с SKIP LOCKED, поэтому RunJobs можно запускать в нескольких
+// экземплярах. Задание в работе, обработчик которого не подавал признаков
+// жизни дольше stale (например, экземпляр упал), выполняется заново,
+// всего не более maxJobAttempts раз; stale должен быть не меньше
+// нескольких секунд. Обработчик вызывается с контекстом, в котором
+// пользователь, поставивший задание, указан через WithActor.
+// Ошибка или паника обработчика сохраняется в задании и не прерывает
+// RunJobs; ошибка БД прерывает.
+func (s *Storage) RunJobs(ctx context.Context, handlers map[string]JobHandler, poll, stale time.Duration) error {
+	kinds := make([]string, 0, len(handlers))
+	for k := range handlers {
+		kinds = append(kinds, k)
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		job, err := s.claimJob(ctx, kinds, stale)
+		if errors.Is(err, ErrJobNotFound) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err = s.runJob(ctx, job, handlers[job.Kind], stale); err != nil {
+			return err
+		}
+	}
+}
+
+// claimJob переводит в работу первое задание из очереди или зависшее
+// задание видов kinds и возвращает его. Зависшие задания, исчерпавшие
+// попытки, помечаются ошибочными.
+func (s *Storage) claimJob(ctx context.Context, kinds []string, stale time.Duration) (Job, error) {
+	if err := s.checkWritable(); err != nil {
+		return Job{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	_, err := s.db.Exec(ctx, `
+		UPDATE jobs
+		SET status = 'failed',
+			error = 'too many attempts',
+			finished = extract(epoch from now())
+		WHERE
+			kind = ANY($1) AND
+			status = 'running' AND
+			heartbeat < extract(epoch from now()) - $2 AND
+			attempts >= $3;
+	`,
+		kinds,
+		int64(stale/time.Second),
+		maxJobAttempts,
+	)
+	if err != nil {
+		return Job{}, err
+	}
+	return scanJob(s.db.QueryRow(ctx, `
+		UPDATE jobs
+		SET status = 'running',
+			attempts = attempts + 1,
+			heartbeat = extract(epoch from now())
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE
+				kind = ANY($1) AND
+				(status = 'queued' OR
+				 status = 'running' AND heartbeat < extract(epoch from now()) - $2)
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns+`;
+	`,
+		kinds,
+		int64(stale/time.Second),
+	))
+}
+
+// runJob выполняет задание обработчиком h и сохраняет результат.
+// Пока обработчик работает, признак жизни задания обновляется
+// не реже чем раз в stale/3. Если задание перешло к другому
+// экземпляру или признак жизни не удаётся обновить дольше stale,
+// обработчик отменяется, а результат не сохраняется.
+func (s *Storage) runJob(ctx context.Context, job Job, h JobHandler, stale time.Duration) error {
+	jobCtx, cancel := context.WithCancel(WithActor(ctx, job.UserID))
+	defer cancel()
+	var done, total int
+	progress := make(chan [2]int, 1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(stale / 3)
+		defer ticker.Stop()
+		alive := time.Now()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case p := <-progress:
+				done, total = p[0], p[1]
+			case <-ticker.C:
+			}
+			owned, err := s.heartbeatJob(jobCtx, job, done, total)
+			switch {
+			case err == nil && !owned:
+				cancel()
+				return
+			case err == nil:
+				alive = time.Now()
+			case time.Since(alive) > stale:
+				// задание уже могло перейти к другому экземпляру
+				cancel()
+				return
+			}
+		}
+	}()
+	report := func(done, total int) {
+		// промежуточные значения можно терять: важно последнее
+		select {
+		case <-progress:
+		default:
+		}
+		progress <- [2]int{done, total}
+	}
+	result, err := callJob(jobCtx, h, job.Params, report)
+	lost := jobCtx.Err() != nil && ctx.Err() == nil
+	cancel()
+	<-finished
+	if lost || ctx.Err() != nil {
+		// при остановке задание будет выполнено заново после stale
+		return nil
+	}
+	return s.finishJob(ctx, job, result, err)
+}
+
+// callJob вызывает обработчик, превращая его панику в ошибку.
+func callJob(ctx context.Context, h JobHandler, params json.RawMessage, progress func(done, total int)) (r JobResult, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job handler panic: %v", p)
+		}
+	}()
+	return h(ctx, params, progress)
+}
+
+// heartbeatJob сохраняет ход выполнения задания и признак жизни
+// и сообщает, числится ли попытка job всё ещё в работе.
+func (s *Storage) heartbeatJob(ctx context.Context, job Job, done, total int) (bool, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tag, err := s.db.Exec(ctx, `
+		UPDATE jobs
+		SET done = $3, total = $4, heartbeat = extract(epoch from now())
+		WHERE id = $1 AND attempts = $2 AND status = 'running';
+	`,
+		job.ID,
+		job.Attempts,
+		done,
+		total,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// finishJob сохраняет результат или ошибку задания. Если задание
+// тем временем перешло к другому экземпляру, результат отбрасывается.
+func (s *Storage) finishJob(ctx context.Context, job Job, r JobResult, jobErr error) error {
+	status, msg := JobDone, ""
+	if jobErr != nil {
+		status, msg, r = JobFailed, jobErr.Error(), JobResult{}
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	_, err := s.db.Exec(ctx, `
+		UPDATE jobs
+		SET status = $3,
+			error = $4,
+			result = $5,
+			result_type = $6,
+			done = CASE WHEN $3 = 'done' THEN greatest(done, total) ELSE done END,
+			finished = extract(epoch from now())
+		WHERE id = $1 AND attempts = $2 AND status = 'running';
+	`,
+		job.ID,
+		job.Attempts,
+		string(status),
+		msg,
+		r.Data,
+		r.ContentType,
+	)
+	return err
+}
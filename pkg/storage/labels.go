@@ -0,0 +1,308 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Метка задачи.
+type Label struct {
+	ID   int
+	Name string
+}
+
+// Число задач с меткой.
+type LabelStat struct {
+	Label
+	Open   int // открытые задачи
+	Closed int // выполненные задачи
+}
+
+// LabelStats возвращает число открытых и выполненных задач для каждой метки.
+// Счётчики поддерживаются триггерами БД, поэтому запрос не сканирует задачи.
+func (s *Storage) LabelStats(ctx context.Context) ([]LabelStat, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT
+			l.id,
+			l.name,
+			coalesce(ls.open_count, 0),
+			coalesce(ls.closed_count, 0)
+		FROM labels l
+		LEFT JOIN label_stats ls ON ls.label_id = l.id
+		ORDER BY l.name;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var stats []LabelStat
+	for rows.Next() {
+		var st LabelStat
+		err = rows.Scan(&st.ID, &st.Name, &st.Open, &st.Closed)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// labelID возвращает id метки с названием name, создавая её при отсутствии.
+func labelID(ctx context.Context, tx pgx.Tx, name string) (int, error) {
+	var id int
+	err := tx.QueryRow(ctx, `
+		INSERT INTO labels (name) VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id;
+		`,
+		name,
+	).Scan(&id)
+	return id, err
+}
+
+// linkLabels добавляет задаче taskID метки labels,
+// создавая отсутствующие метки.
+func linkLabels(ctx context.Context, tx pgx.Tx, taskID int, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO labels (name)
+		SELECT unnest($1::text[])
+		ON CONFLICT (name) DO NOTHING;
+		`,
+		labels,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO tasks_labels (task_id, label_id)
+		SELECT $1, id FROM labels WHERE name = ANY($2)
+		ON CONFLICT DO NOTHING;
+		`,
+		taskID,
+		labels,
+	)
+	if err != nil {
+		return err
+	}
+	return addLabelsEvent(ctx, tx, []int{taskID})
+}
+
+// AddLabelToTasks добавляет метку labelName к задачам из списка taskIDs
+// одним запросом, создавая метку при необходимости. Задачи, у которых
+// метка уже есть, считаются успешно обработанными.
+// Результаты возвращаются в порядке taskIDs.
+func (s *Storage) AddLabelToTasks(ctx context.Context, labelName string, taskIDs []int) (BatchResult, error) {
+	if err := s.checkWritable(); err != nil {
+		return BatchResult{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	id, err := labelID(ctx, tx, labelName)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO tasks_labels (task_id, label_id)
+		SELECT t.id, $2 FROM tasks t WHERE t.id = ANY($1)
+		ON CONFLICT DO NOTHING;
+		`,
+		taskIDs,
+		id,
+	)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	if err = addLabelsEvent(ctx, tx, taskIDs); err != nil {
+		return BatchResult{}, err
+	}
+	return labelResults(ctx, tx, taskIDs)
+}
+
+// RemoveLabelFromTasks снимает метку labelName с задач из списка taskIDs
+// одним запросом. Результаты возвращаются в порядке taskIDs.
+func (s *Storage) RemoveLabelFromTasks(ctx context.Context, labelName string, taskIDs []int) (BatchResult, error) {
+	if err := s.checkWritable(); err != nil {
+		return BatchResult{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM tasks_labels
+		WHERE task_id = ANY($1) AND label_id IN (
+			SELECT id FROM labels WHERE name = $2
+		);
+		`,
+		taskIDs,
+		labelName,
+	)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	if err = addLabelsEvent(ctx, tx, taskIDs); err != nil {
+		return BatchResult{}, err
+	}
+	return labelResults(ctx, tx, taskIDs)
+}
+
+// labelResults фиксирует транзакцию изменения меток
+// и собирает результаты по каждой задаче.
+func labelResults(ctx context.Context, tx pgx.Tx, taskIDs []int) (BatchResult, error) {
+	found, err := existingTasks(ctx, tx, taskIDs)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	return commitBatch(ctx, tx, itemResults(taskIDs, found))
+}
+
+// Режим сопоставления меток в TasksByLabels.
+type LabelMatch int
+
+const (
+	// MatchAny - задача имеет хотя бы одну из меток.
+	MatchAny LabelMatch = iota
+	// MatchAll - задача имеет все метки.
+	MatchAll
+)
+
+// TasksByLabels возвращает задачи, имеющие любую (MatchAny)
+// или каждую (MatchAll) из меток labels.
+func (s *Storage) TasksByLabels(ctx context.Context, labels []string, mode LabelMatch) ([]Task, error) {
+	// повторы меток не должны влиять на подсчёт совпадений
+	uniq := make(map[string]bool, len(labels))
+	names := make([]string, 0, len(labels))
+	for _, l := range labels {
+		if !uniq[l] {
+			uniq[l] = true
+			names = append(names, l)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	need := 1
+	if mode == MatchAll {
+		need = len(names)
+	}
+
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE NOT t.draft AND t.id IN (
+			SELECT tl.task_id
+			FROM tasks_labels tl
+			JOIN labels l ON l.id = tl.label_id
+			WHERE l.name = ANY($1)
+			GROUP BY tl.task_id
+			HAVING count(DISTINCT l.id) >= $2
+		)
+		ORDER BY t.id;
+	`,
+		names,
+		need,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows, 0)
+}
+
+// TaskLabels возвращает метки задачи в алфавитном порядке.
+func (s *Storage) TaskLabels(ctx context.Context, taskID int) ([]Label, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT l.id, l.name
+		FROM labels l
+		JOIN tasks_labels tl ON tl.label_id = l.id
+		WHERE tl.task_id = $1
+		ORDER BY l.name;
+	`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var labels []Label
+	for rows.Next() {
+		var l Label
+		if err = rows.Scan(&l.ID, &l.Name); err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	return labels, rows.Err()
+}
+
+// Задача вместе с её метками.
+type LabeledTask struct {
+	Task
+	Labels []Label
+}
+
+// TasksWithLabels возвращает задачи, подходящие под фильтр, вместе с их
+// метками. Метки всех задач загружаются одним дополнительным запросом.
+func (s *Storage) TasksWithLabels(ctx context.Context, f TaskFilter) ([]LabeledTask, error) {
+	tasks, err := s.TasksByFilter(ctx, f)
+	if err != nil || len(tasks) == 0 {
+		return nil, err
+	}
+	ids := make([]int, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT tl.task_id, l.id, l.name
+		FROM tasks_labels tl
+		JOIN labels l ON l.id = tl.label_id
+		WHERE tl.task_id = ANY($1)
+		ORDER BY l.name;
+	`,
+		ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	labels := make(map[int][]Label)
+	for rows.Next() {
+		var taskID int
+		var l Label
+		if err = rows.Scan(&taskID, &l.ID, &l.Name); err != nil {
+			return nil, err
+		}
+		labels[taskID] = append(labels[taskID], l)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]LabeledTask, len(tasks))
+	for i, t := range tasks {
+		result[i] = LabeledTask{Task: t, Labels: labels[t.ID]}
+	}
+	return result, nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ключ рекомендательной блокировки периодических заданий,
+// второй ключ - хэш имени задания
+const jobLockKey = 388
+
+// RunExclusive выполняет fn, только если ни один другой экземпляр
+// сервиса не выполняет сейчас задание с тем же именем, и сообщает,
+// было ли задание выполнено. Вызов не ждёт: проигравший экземпляр
+// пропускает запуск. Блокировка берётся на каждый запуск, а не на
+// постоянное лидерство, так что задания распределяются между
+// экземплярами, а упавший экземпляр заменяется на следующем запуске.
+// Взаимное исключение обеспечивается сессионной рекомендательной
+// блокировкой на отдельном соединении пула, удерживаемом на всё время
+// выполнения fn: блокировка уровня транзакции держала бы транзакцию
+// открытой всё это время.
+// Блокировка освобождается сервером и при обрыве соединения; в этом
+// случае контекст fn отменяется при следующей проверке соединения,
+// выполняемой раз в checkEvery (0 - без проверок).
+func (s *Storage) RunExclusive(ctx context.Context, name string, checkEvery time.Duration, fn func(ctx context.Context) error) (bool, error) {
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	var locked bool
+	err = conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1, hashtext($2));`, jobLockKey, name).Scan(&locked)
+	if err != nil || !locked {
+		return false, err
+	}
+	defer func() {
+		// контекст вызова может быть уже отменён, а блокировку нужно снять,
+		// иначе она останется за соединением, возвращённым в пул
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1, hashtext($2));`, jobLockKey, name)
+		if err != nil {
+			conn.Conn().Close(unlockCtx)
+		}
+	}()
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if checkEvery > 0 {
+		// соединение с блокировкой не должно использоваться одновременно
+		// с её снятием, поэтому проверка завершается до выхода
+		var wg sync.WaitGroup
+		wg.Add(1)
+		defer wg.Wait()
+		defer cancel()
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(checkEvery)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-jobCtx.Done():
+					return
+				case <-ticker.C:
+				}
+				if err := conn.Ping(jobCtx); err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+	return true, fn(jobCtx)
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// SlowQueries - число запросов, превысивших порог WithSlowQuery,
+// публикуется через expvar.
+var SlowQueries = expvar.NewInt("storage_slow_queries")
+
+// Logger получает структурированный журнал запросов к БД:
+// текст запроса, параметры, длительность и идентификатор запроса.
+type Logger interface {
+	Log(ctx context.Context, msg string, fields map[string]any)
+}
+
+// queryLogger передаёт журнал драйвера pgx в Logger,
+// дополняя записи идентификатором запроса из контекста.
+// Если задан порог slow, в журнал попадают только медленные
+// запросы и ошибки.
+type queryLogger struct {
+	l    Logger // может быть nil, если нужен только счётчик
+	slow time.Duration
+}
+
+func (q queryLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]any) {
+	if q.slow > 0 && level > pgx.LogLevelError {
+		d, _ := data["time"].(time.Duration)
+		if d < q.slow {
+			return
+		}
+		SlowQueries.Add(1)
+		msg = "slow " + msg
+	}
+	if q.l == nil {
+		return
+	}
+	fields := make(map[string]any, len(data)+2)
+	for k, v := range data {
+		fields[k] = v
+	}
+	fields["level"] = level.String()
+	if id := RequestID(ctx); id != "" {
+		fields["request_id"] = id
+	}
+	q.l.Log(ctx, msg, fields)
+}
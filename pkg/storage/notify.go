@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// NotifyChannel - канал LISTEN/NOTIFY, в который триггеры БД
+// отправляют id изменённой задачи при изменении задачи или её меток.
+const NotifyChannel = "tasks_changed"
+
+// Listen подписывается на канал channel и вызывает fn с полезной
+// нагрузкой каждого уведомления до отмены контекста или ошибки соединения.
+// Сразу после подписки fn вызывается с пустой строкой: получатель
+// должен сбросить состояние, так как уведомления, отправленные
+// до подписки, потеряны.
+// На время подписки соединение изымается из пула.
+func (s *Storage) Listen(ctx context.Context, channel string, fn func(payload string)) error {
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// соединение с активной подпиской нельзя возвращать в пул
+	defer func() {
+		conn.Conn().Close(context.Background())
+		conn.Release()
+	}()
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize())
+	if err != nil {
+		return err
+	}
+	fn("")
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Payload)
+	}
+}
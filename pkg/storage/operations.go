@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrSelfMerge возвращается при попытке объединить задачу с самой собой.
+var ErrSelfMerge = errors.New("cannot merge task into itself")
+
+// CloneTask создаёт новую открытую задачу по образцу задачи id,
+// копируя её заголовок, содержание, срок, приоритет, оценку, эпик,
+// автора, ответственного и метки.
+// Ненулевые поля overrides (кроме ID, Opened и Closed) заменяют
+// скопированные значения. Возвращает созданную задачу.
+func (s *Storage) CloneTask(ctx context.Context, id int, overrides Task) (Task, error) {
+	if err := s.checkWritable(); err != nil {
+		return Task{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Task{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	src, err := scanTask(tx.QueryRow(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE t.id = $1
+		FOR SHARE;
+	`,
+		id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Task{}, ErrTaskNotFound
+	}
+	if err != nil {
+		return Task{}, err
+	}
+	clone := Task{
+		AuthorID:   src.AuthorID,
+		AssignedID: src.AssignedID,
+		Title:      src.Title,
+		Content:    src.Content,
+		Due:        src.Due,
+		Priority:   src.Priority,
+		Estimate:   src.Estimate,
+		EpicID:     src.EpicID,
+	}
+	for _, f := range []struct{ dst, src *int }{
+		{&clone.AuthorID, &overrides.AuthorID},
+		{&clone.AssignedID, &overrides.AssignedID},
+		{&clone.Priority, &overrides.Priority},
+		{&clone.Estimate, &overrides.Estimate},
+		{&clone.EpicID, &overrides.EpicID},
+	} {
+		if *f.src != 0 {
+			*f.dst = *f.src
+		}
+	}
+	if overrides.Title != "" {
+		clone.Title = overrides.Title
+	}
+	if overrides.Content != "" {
+		clone.Content = overrides.Content
+	}
+	if overrides.Due != 0 {
+		clone.Due = overrides.Due
+	}
+	// UID выдаётся так же, как при создании задачи
+	if clone, err = s.insertTask(ctx, tx, clone); err != nil {
+		return Task{}, err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO tasks_labels (task_id, label_id)
+		SELECT $1, label_id FROM tasks_labels WHERE task_id = $2;
+		`,
+		clone.ID,
+		id,
+	)
+	if err != nil {
+		return Task{}, err
+	}
+	if err = addLabelsEvent(ctx, tx, []int{clone.ID}); err != nil {
+		return Task{}, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return Task{}, err
+	}
+	return clone, nil
+}
+
+// MergeTasks объединяет задачу srcID с задачей dstID: переносит метки
+// исходной задачи и закрывает её с отметкой "merged into #dstID" в содержании.
+// Все изменения выполняются в одной транзакции.
+func (s *Storage) MergeTasks(ctx context.Context, srcID, dstID int) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if srcID == dstID {
+		return ErrSelfMerge
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// блокировка обеих задач в порядке id исключает взаимоблокировку
+	// при встречных объединениях
+	var n int
+	err = tx.QueryRow(ctx, `
+		SELECT count(*) FROM (
+			SELECT id FROM tasks WHERE id IN ($1, $2) ORDER BY id FOR UPDATE
+		) locked;
+		`,
+		srcID,
+		dstID,
+	).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n != 2 {
+		return ErrTaskNotFound
+	}
+	var closed int64
+	err = tx.QueryRow(ctx, `SELECT coalesce(closed, 0) FROM tasks WHERE id = $1;`, srcID).Scan(&closed)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO tasks_labels (task_id, label_id)
+		SELECT $2, label_id FROM tasks_labels
+		WHERE task_id = $1 AND label_id NOT IN (
+			SELECT label_id FROM tasks_labels WHERE task_id = $2
+		);
+		`,
+		srcID,
+		dstID,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM tasks_labels WHERE task_id = $1;`, srcID)
+	if err != nil {
+		return err
+	}
+
+	src, err := scanTask(tx.QueryRow(ctx, `
+		UPDATE tasks AS t
+		SET closed = CASE WHEN coalesce(t.closed, 0) = 0 THEN extract(epoch from now()) ELSE t.closed END,
+			content = concat_ws(E'\n\n', nullif(t.content, ''), $2::text)
+		WHERE t.id = $1
+		RETURNING `+taskColumns+`;
+		`,
+		srcID,
+		fmt.Sprintf("merged into #%d", dstID),
+	))
+	if err != nil {
+		return err
+	}
+	if err = addEvent(ctx, tx, updateEvent(closed, src), src); err != nil {
+		return err
+	}
+	dst, err := scanTask(tx.QueryRow(ctx, `
+		SELECT `+taskColumns+` FROM tasks t WHERE t.id = $1;
+		`,
+		dstID,
+	))
+	if err != nil {
+		return err
+	}
+	if err = addEvent(ctx, tx, EventTaskUpdated, dst); err != nil {
+		return err
+	}
+	if err = addLabelsEvent(ctx, tx, []int{srcID, dstID}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
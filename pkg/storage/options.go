@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Параметры хранилища, задаваемые опциями конструктора.
+type config struct {
+	queryTimeout time.Duration // таймаут запроса по умолчанию
+	authorQuota  int           // предел открытых задач автора, 0 - без предела
+
+	connectAttempts int           // число попыток подключения
+	connectBackoff  time.Duration // пауза перед второй попыткой, далее удваивается
+	lazyConnect     bool          // подключаться при первом запросе
+
+	readOnly    bool // только чтение
+	checkSchema bool // проверять схему БД при создании
+
+	logger    Logger        // журнал запросов
+	slowQuery time.Duration // порог медленного запроса
+
+	passwordParams PasswordParams // параметры хэширования паролей
+
+	defaults Defaults      // значения по умолчанию для новых задач
+	newUID   func() string // генератор UID задач, nil - UUID сервера
+}
+
+// Option настраивает хранилище при создании.
+type Option func(*config)
+
+// WithQueryTimeout задаёт таймаут по умолчанию для каждого запроса к БД.
+// Помимо дедлайна контекста, таймаут передаётся серверу
+// как statement_timeout, чтобы долгий запрос не удерживал соединение
+// пула, даже если отмена от драйвера не дошла. Таймаут можно
+// переопределить для отдельного вызова через WithTimeout.
+func WithQueryTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.queryTimeout = d
+	}
+}
+
+// WithAuthorQuota ограничивает число открытых задач одного автора.
+// При превышении создание задачи завершается ошибкой ErrQuotaExceeded.
+func WithAuthorQuota(n int) Option {
+	return func(c *config) {
+		c.authorQuota = n
+	}
+}
+
+// WithConnectRetry повторяет неудачное подключение к БД при создании
+// хранилища до attempts раз, удваивая паузу между попытками начиная
+// с backoff. Полезно, когда сервис запускается раньше БД.
+func WithConnectRetry(attempts int, backoff time.Duration) Option {
+	return func(c *config) {
+		c.connectAttempts = attempts
+		c.connectBackoff = backoff
+	}
+}
+
+// WithLazyConnect откладывает подключение к БД до первого запроса,
+// так что конструктор не завершается ошибкой, если БД ещё недоступна.
+func WithLazyConnect() Option {
+	return func(c *config) {
+		c.lazyConnect = true
+	}
+}
+
+// WithReadOnly переводит хранилище в режим только для чтения:
+// изменяющие методы возвращают ErrReadOnly, а транзакции соединений
+// по умолчанию открываются только для чтения. Подходит для окон
+// обслуживания и экземпляров отчётов, работающих с репликой.
+func WithReadOnly() Option {
+	return func(c *config) {
+		c.readOnly = true
+	}
+}
+
+// WithSchemaCheck проверяет схему БД методом CheckSchema при создании
+// хранилища, так что конструктор возвращает ErrSchemaMismatch для
+// несовместимой БД. С WithLazyConnect проверка не выполняется.
+func WithSchemaCheck() Option {
+	return func(c *config) {
+		c.checkSchema = true
+	}
+}
+
+// WithLogger направляет журнал запросов к БД в l.
+func WithLogger(l Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// WithSlowQuery задаёт порог длительности медленного запроса.
+// Запросы дольше порога учитываются счётчиком SlowQueries и вместе
+// с текстом, кратким списком параметров и длительностью попадают
+// в журнал WithLogger; остальные успешные запросы в журнал не пишутся.
+func WithSlowQuery(d time.Duration) Option {
+	return func(c *config) {
+		c.slowQuery = d
+	}
+}
+
+// WithPasswordParams задаёт параметры хэширования паролей argon2id
+// вместо DefaultPasswordParams. Хэши, вычисленные с другими параметрами,
+// пересчитываются при следующем успешном входе.
+func WithPasswordParams(p PasswordParams) Option {
+	return func(c *config) {
+		c.passwordParams = p
+	}
+}
+
+// ключ контекста для таймаута отдельного вызова.
+type timeoutKey struct{}
+
+// WithTimeout переопределяет таймаут по умолчанию для вызовов
+// с возвращённым контекстом, в том числе statement_timeout сервера.
+// Нулевое значение отключает таймаут.
+func WithTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, d)
+}
+
+// queryContext ограничивает время выполнения запроса таймаутом вызова,
+// а если он не задан - таймаутом по умолчанию.
+func (s *Storage) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	d := s.cfg.queryTimeout
+	if v, ok := ctx.Value(timeoutKey{}).(time.Duration); ok {
+		d = v
+	}
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// statementTimeouts согласует statement_timeout соединений пула
+// с таймаутом вызова. Таймаут по умолчанию задаётся параметром
+// соединения, а для вызова с WithTimeout значение меняется при выдаче
+// соединения из пула и возвращается при его освобождении, так что
+// лишний запрос к серверу выполняется только для таких вызовов.
+type statementTimeouts struct {
+	def time.Duration
+
+	mu  sync.Mutex
+	set map[*pgx.Conn]bool // соединения с изменённым таймаутом
+}
+
+func newStatementTimeouts(def time.Duration) *statementTimeouts {
+	return &statementTimeouts{def: def, set: make(map[*pgx.Conn]bool)}
+}
+
+// beforeAcquire устанавливает для conn таймаут вызова из ctx.
+// При ошибке соединение закрывается, и пул выдаёт другое.
+func (st *statementTimeouts) beforeAcquire(ctx context.Context, conn *pgx.Conn) bool {
+	d, ok := ctx.Value(timeoutKey{}).(time.Duration)
+	if !ok || d == st.def {
+		return true
+	}
+	if err := setStatementTimeout(ctx, conn, d); err != nil {
+		return false
+	}
+	st.mu.Lock()
+	st.set[conn] = true
+	st.mu.Unlock()
+	return true
+}
+
+// afterRelease возвращает conn таймаут по умолчанию.
+func (st *statementTimeouts) afterRelease(conn *pgx.Conn) bool {
+	st.mu.Lock()
+	set := st.set[conn]
+	delete(st.set, conn)
+	st.mu.Unlock()
+	if !set {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return setStatementTimeout(ctx, conn, st.def) == nil
+}
+
+// setStatementTimeout задаёт statement_timeout сессии conn;
+// d <= 0 отключает таймаут.
+func setStatementTimeout(ctx context.Context, conn *pgx.Conn, d time.Duration) error {
+	_, err := conn.Exec(ctx, "SELECT set_config('statement_timeout', $1, false)", statementTimeout(d))
+	return err
+}
+
+// statementTimeout переводит d в значение statement_timeout
+// в миллисекундах, округляя вверх, чтобы малый таймаут не стал нулём.
+func statementTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "0"
+	}
+	return strconv.FormatInt(int64((d+time.Millisecond-1)/time.Millisecond), 10)
+}
+
+// ключ контекста для пробного запуска.
+type dryRunKey struct{}
+
+// WithDryRun включает пробный запуск разрушающих операций для вызовов
+// с возвращённым контекстом: удаление выполняется в транзакции, которая
+// затем откатывается, а результат показывает, что было бы затронуто.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun сообщает, включён ли в контексте пробный запуск.
+func IsDryRun(ctx context.Context) bool {
+	v, _ := ctx.Value(dryRunKey{}).(bool)
+	return v
+}
+
+// finish фиксирует транзакцию разрушающей операции,
+// а при пробном запуске откатывает её.
+func finish(ctx context.Context, tx pgx.Tx) error {
+	if IsDryRun(ctx) {
+		return tx.Rollback(ctx)
+	}
+	return tx.Commit(ctx)
+}
+
+// ключ контекста для идентификатора запроса.
+type requestIDKey struct{}
+
+// WithRequestID связывает с контекстом идентификатор входящего запроса.
+// Идентификатор добавляется комментарием к тексту каждого запроса к БД
+// и полем request_id в журнал запросов. Символы, недопустимые
+// в SQL-комментарии, отбрасываются.
+// Текст помеченного запроса уникален, поэтому запрос подготавливается
+// заново и вытесняет другие операторы из кэша соединения: идентификатор
+// стоит передавать только там, где нужна трассировка.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, sanitizeRequestID(id))
+}
+
+// RequestID возвращает идентификатор запроса из контекста.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ключ контекста для пользователя, выполняющего действие.
+type actorKey struct{}
+
+// WithActor связывает с контекстом пользователя, от имени которого
+// выполняются вызовы; он записывается в журнал аудита.
+func WithActor(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// Actor возвращает пользователя, выполняющего действие, или 0.
+func Actor(ctx context.Context) int {
+	id, _ := ctx.Value(actorKey{}).(int)
+	return id
+}
+
+// ключ контекста для часового пояса.
+type locationKey struct{}
+
+// WithLocation задаёт часовой пояс, в котором для вызовов с возвращённым
+// контекстом определяются границы дней, например в TasksDueToday.
+func WithLocation(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, locationKey{}, loc)
+}
+
+// Location возвращает часовой пояс из контекста или nil.
+func Location(ctx context.Context) *time.Location {
+	loc, _ := ctx.Value(locationKey{}).(*time.Location)
+	return loc
+}
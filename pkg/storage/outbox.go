@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Типы событий жизненного цикла задачи.
+const (
+	EventTaskCreated = "task.created"
+	EventTaskUpdated = "task.updated"
+	EventTaskDeleted = "task.deleted"
+	// EventTaskClosed - закрытие открытой задачи; остальные изменения,
+	// в том числе повторное открытие, публикуются как EventTaskUpdated.
+	EventTaskClosed = "task.closed"
+	// EventTaskLabels - изменение меток задачи; в отличие от остальных
+	// событий, содержимое - не состояние задачи, а TaskLabels.
+	EventTaskLabels = "task.labels"
+)
+
+// Метки задачи в событии EventTaskLabels.
+type TaskLabels struct {
+	ID     int      `json:"id"`
+	Labels []string `json:"labels"` // названия меток по алфавиту
+}
+
+// Событие из таблицы outbox.
+type Event struct {
+	ID      int64
+	Created int64
+	Type    string
+	TaskID  int
+	Payload []byte // состояние задачи или TaskLabels в формате JSON
+}
+
+// Publisher доставляет события во внешнюю систему.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// addEvent записывает событие об изменении задачи в outbox
+// в рамках той же транзакции, что и само изменение.
+func addEvent(ctx context.Context, tx pgx.Tx, typ string, t Task) error {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO outbox (type, task_id, payload)
+		VALUES ($1, $2, $3);
+		`,
+		typ,
+		t.ID,
+		payload,
+	)
+	return err
+}
+
+// updateEvent возвращает тип события об изменении задачи t,
+// которая до изменения была закрыта в момент closed (0 - открыта).
+func updateEvent(closed int64, t Task) string {
+	if closed == 0 && t.Closed != 0 {
+		return EventTaskClosed
+	}
+	return EventTaskUpdated
+}
+
+// addLabelsEvent записывает в outbox события EventTaskLabels
+// с текущими метками задач taskIDs; отсутствующие задачи пропускаются.
+func addLabelsEvent(ctx context.Context, tx pgx.Tx, taskIDs []int) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO outbox (type, task_id, payload)
+		SELECT $2, t.id, jsonb_build_object(
+			'id', t.id,
+			'labels', coalesce((
+				SELECT array_agg(l.name ORDER BY l.name)
+				FROM tasks_labels tl
+				JOIN labels l ON l.id = tl.label_id
+				WHERE tl.task_id = t.id
+			), '{}')
+		)
+		FROM tasks t
+		WHERE t.id = ANY($1)
+		ORDER BY t.id;
+		`,
+		taskIDs,
+		EventTaskLabels,
+	)
+	return err
+}
+
+// PublishEvents отправляет до limit неопубликованных событий в порядке
+// их создания и помечает отправленные. Возвращает число опубликованных событий.
+// Если публикация прервалась ошибкой, уже отправленные события
+// всё равно помечаются, а оставшиеся будут отправлены при следующем вызове.
+func (s *Storage) PublishEvents(ctx context.Context, p Publisher, limit int) (int, error) {
+	if err := s.checkWritable(); err != nil {
+		return 0, err
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// SKIP LOCKED позволяет нескольким диспетчерам работать параллельно
+	rows, err := tx.Query(ctx, `
+		SELECT id, created, type, task_id, payload
+		FROM outbox
+		WHERE published = 0
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED;
+	`,
+		limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var events []Event
+	for rows.Next() {
+		var e Event
+		err = rows.Scan(&e.ID, &e.Created, &e.Type, &e.TaskID, &e.Payload)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var sent []int64
+	var pubErr error
+	for _, e := range events {
+		if pubErr = p.Publish(ctx, e); pubErr != nil {
+			break
+		}
+		sent = append(sent, e.ID)
+	}
+	if len(sent) > 0 {
+		_, err = tx.Exec(ctx, `
+			UPDATE outbox
+			SET published = extract(epoch from now())
+			WHERE id = ANY($1);
+			`,
+			sent,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(sent), pubErr
+}
+
+// Dispatcher периодически публикует события из outbox.
+// События доставляются как минимум один раз: после сбоя процесса
+// между публикацией и фиксацией транзакции событие будет отправлено повторно.
+type Dispatcher struct {
+	s         *Storage
+	p         Publisher
+	interval  time.Duration
+	batchSize int
+
+	// OnError, если задан, вызывается при ошибках публикации.
+	OnError func(error)
+}
+
+// NewDispatcher создаёт диспетчер, опрашивающий outbox с интервалом interval.
+func NewDispatcher(s *Storage, p Publisher, interval time.Duration) *Dispatcher {
+	return &Dispatcher{
+		s:         s,
+		p:         p,
+		interval:  interval,
+		batchSize: 100,
+	}
+}
+
+// Run публикует события до отмены контекста.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		// выбираем очередь полностью, пока есть полные пачки событий
+		for {
+			n, err := d.s.PublishEvents(ctx, d.p, d.batchSize)
+			if err != nil && d.OnError != nil && ctx.Err() == nil {
+				d.OnError(err)
+			}
+			if err != nil || n < d.batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// OutboxBacklog возвращает число неопубликованных событий outbox.
+func (s *Storage) OutboxBacklog(ctx context.Context) (int64, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var n int64
+	err := s.db.QueryRow(ctx, `SELECT count(*) FROM outbox WHERE published = 0;`).Scan(&n)
+	return n, err
+}
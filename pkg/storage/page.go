@@ -0,0 +1,51 @@
+package storage
+
+import "context"
+
+// Сведения о странице списка задач.
+type PageInfo struct {
+	Total      int  // число задач, подходящих под фильтр, на всех страницах
+	HasNext    bool // есть следующая страница
+	NextCursor int  // AfterID следующей страницы при порядке OrderID без StarredBy
+	NextOffset int  // Offset следующей страницы
+}
+
+// TasksPage возвращает страницу задач, подходящих под фильтр,
+// и сведения для перехода к следующей странице. Общее число задач
+// считается отдельным запросом без учёта курсора и смещения.
+func (s *Storage) TasksPage(ctx context.Context, f TaskFilter) ([]Task, PageInfo, error) {
+	var info PageInfo
+	// лишняя строка показывает, есть ли следующая страница
+	page := f
+	if f.Limit > 0 {
+		page.Limit = f.Limit + 1
+	}
+	tasks, err := s.TasksByFilter(ctx, page)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	if f.Limit > 0 && len(tasks) > f.Limit {
+		tasks = tasks[:f.Limit]
+		info.HasNext = true
+		info.NextOffset = f.Offset + f.Limit
+		// при StarredBy порядок не совпадает с порядком id
+		if f.Order == OrderID && f.StarredBy == 0 {
+			info.NextCursor = tasks[len(tasks)-1].ID
+		}
+	}
+
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	all := f
+	all.AfterID = 0
+	where, args := all.where(nil)
+	err = s.db.QueryRow(ctx, `
+		SELECT count(*) FROM tasks t WHERE `+where+`;
+		`,
+		args...,
+	).Scan(&info.Total)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	return tasks, info, nil
+}
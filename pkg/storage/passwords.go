@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials возвращается VerifyPassword при неверном адресе
+// или пароле, а также пользователю без пароля.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrPasswordTooLong возвращается SetPassword для пароля
+// длиннее maxPasswordLen байт.
+var ErrPasswordTooLong = errors.New("password is too long")
+
+// ErrEmptyPassword возвращается SetPassword для пустого пароля.
+var ErrEmptyPassword = errors.New("password is empty")
+
+// ErrUserNotFound возвращается, когда пользователя с указанным id нет.
+var ErrUserNotFound = errors.New("user not found")
+
+// PasswordParams - параметры хэширования паролей argon2id.
+type PasswordParams struct {
+	Time    uint32 // число проходов
+	Memory  uint32 // память в КиБ
+	Threads uint8
+}
+
+// DefaultPasswordParams - параметры по умолчанию, рекомендованные RFC 9106
+// для систем с ограниченной памятью.
+var DefaultPasswordParams = PasswordParams{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+const (
+	passwordSaltLen = 16
+	passwordKeyLen  = 32
+
+	// наибольшая длина пароля в байтах
+	maxPasswordLen = 1024
+)
+
+// Границы параметров хэша, прочитанного из БД: хэш с параметрами
+// вне границ отвергается, а не вычисляется, чтобы испорченная
+// или подложенная запись не роняла вход и не занимала память без меры.
+const (
+	maxHashTime    = 16
+	maxHashMemory  = 1 << 20 // КиБ, 1 ГиБ
+	maxHashThreads = 64
+	minHashKeyLen  = 16
+	maxHashKeyLen  = 64
+	minHashSaltLen = 8
+	maxHashSaltLen = 64
+)
+
+// valid сообщает, находятся ли параметры в допустимых границах.
+func (p PasswordParams) valid() bool {
+	return p.Time >= 1 && p.Time <= maxHashTime &&
+		p.Threads >= 1 && p.Threads <= maxHashThreads &&
+		p.Memory >= 8*uint32(p.Threads) && p.Memory <= maxHashMemory
+}
+
+// hashPassword возвращает хэш пароля argon2id в формате PHC.
+func hashPassword(password string, p PasswordParams) (string, error) {
+	salt := make([]byte, passwordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, passwordKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// checkPassword сравнивает пароль с хэшем argon2id или bcrypt
+// и сообщает, нужно ли пересчитать хэш с параметрами p.
+func checkPassword(hash, password string, p PasswordParams) (ok, rehash bool) {
+	if strings.HasPrefix(hash, "$2") {
+		// хэши bcrypt принимаются и заменяются на argon2id
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, true
+	}
+	var version int
+	var hp PasswordParams
+	var salt, key string
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return rejectPassword(password, p)
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return rejectPassword(password, p)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hp.Memory, &hp.Time, &hp.Threads); err != nil {
+		return rejectPassword(password, p)
+	}
+	if version != argon2.Version || !hp.valid() {
+		return rejectPassword(password, p)
+	}
+	salt, key = parts[4], parts[5]
+	saltBytes, err := base64.RawStdEncoding.DecodeString(salt)
+	if err != nil || len(saltBytes) < minHashSaltLen || len(saltBytes) > maxHashSaltLen {
+		return rejectPassword(password, p)
+	}
+	want, err := base64.RawStdEncoding.DecodeString(key)
+	if err != nil || len(want) < minHashKeyLen || len(want) > maxHashKeyLen {
+		return rejectPassword(password, p)
+	}
+	got := argon2.IDKey([]byte(password), saltBytes, hp.Time, hp.Memory, hp.Threads, uint32(len(want)))
+	ok = subtle.ConstantTimeCompare(got, want) == 1
+	return ok, hp != p
+}
+
+// rejectPassword вычисляет хэш пароля с параметрами p впустую
+// и возвращает отказ, чтобы время ответа не выдавало, что у учётной
+// записи нет пароля или её хэш не разбирается.
+func rejectPassword(password string, p PasswordParams) (ok, rehash bool) {
+	argon2.IDKey([]byte(password), make([]byte, passwordSaltLen), p.Time, p.Memory, p.Threads, passwordKeyLen)
+	return false, false
+}
+
+// SetPassword задаёт пароль пользователя и записывает смену в журнал аудита.
+// Пустой пароль отвергается с ErrEmptyPassword: пустой хэш в БД
+// означает, что пароль не задан. Пароль длиннее maxPasswordLen байт
+// отвергается с ErrPasswordTooLong.
+func (s *Storage) SetPassword(ctx context.Context, userID int, password string) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if password == "" {
+		return ErrEmptyPassword
+	}
+	if len(password) > maxPasswordLen {
+		return ErrPasswordTooLong
+	}
+	release, err := s.acquireHashing(ctx)
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(password, s.cfg.passwordParams)
+	release()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, `UPDATE users SET password_hash = $2 WHERE id = $1;`, userID, hash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	if err = addAudit(ctx, tx, AuditPasswordChange, target("user", userID), nil); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// VerifyPassword проверяет пароль пользователя с адресом email
+// и возвращает пользователя или ErrInvalidCredentials.
+// Если хэш пароля получен с устаревшими параметрами или алгоритмом,
+// он пересчитывается с текущими параметрами.
+// Успешные и неудачные попытки входа записываются в журнал аудита.
+// Пароль длиннее maxPasswordLen байт считается неверным без хэширования,
+// а число одновременных вычислений хэша ограничено (см. acquireHashing).
+func (s *Storage) VerifyPassword(ctx context.Context, email, password string) (User, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	if len(password) > maxPasswordLen {
+		return User{}, s.loginFailed(ctx, email)
+	}
+	release, err := s.acquireHashing(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	defer release()
+	var u User
+	var hash string
+	err = s.db.QueryRow(ctx, `
+		SELECT id, name, email, password_hash
+		FROM users
+		WHERE lower(email) = lower($1) AND email <> '';
+	`,
+		email,
+	).Scan(&u.ID, &u.Name, &u.Email, &hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// хэширование и для неизвестного адреса, чтобы время ответа
+		// не выдавало существование пользователя
+		hashPassword(password, s.cfg.passwordParams)
+		return User{}, s.loginFailed(ctx, email)
+	}
+	if err != nil {
+		return User{}, err
+	}
+	ok, rehash := checkPassword(hash, password, s.cfg.passwordParams)
+	if !ok {
+		return User{}, s.loginFailed(ctx, email)
+	}
+	if rehash && !s.cfg.readOnly {
+		newHash, err := hashPassword(password, s.cfg.passwordParams)
+		if err != nil {
+			return User{}, err
+		}
+		// пароль мог смениться параллельно, поэтому заменяется только проверенный хэш
+		_, err = s.db.Exec(ctx, `
+			UPDATE users SET password_hash = $3
+			WHERE id = $1 AND password_hash = $2;
+		`,
+			u.ID,
+			hash,
+			newHash,
+		)
+		if err != nil {
+			return User{}, err
+		}
+	}
+	if !s.cfg.readOnly {
+		err = addAudit(WithActor(ctx, u.ID), s.db, AuditLogin, target("user", u.ID), map[string]any{"method": "password"})
+		if err != nil {
+			return User{}, err
+		}
+	}
+	return u, nil
+}
+
+// loginFailed записывает неудачный вход в журнал аудита
+// и возвращает ErrInvalidCredentials.
+func (s *Storage) loginFailed(ctx context.Context, email string) error {
+	if !s.cfg.readOnly {
+		err := addAudit(ctx, s.db, AuditLoginFailed, "", map[string]any{"email": email})
+		if err != nil {
+			return err
+		}
+	}
+	return ErrInvalidCredentials
+}
+
+// acquireHashing ждёт свободного места для вычисления хэша пароля:
+// каждое вычисление занимает PasswordParams.Memory памяти, и без
+// ограничения поток попыток входа исчерпал бы память процесса.
+// Возвращённую функцию нужно вызвать по окончании вычисления.
+func (s *Storage) acquireHashing(ctx context.Context) (func(), error) {
+	select {
+	case s.hashing <- struct{}{}:
+		return func() { <-s.hashing }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
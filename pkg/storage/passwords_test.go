@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPassword(t *testing.T) {
+	// дешёвые параметры, чтобы тест выполнялся быстро
+	cheap := PasswordParams{Time: 1, Memory: 64, Threads: 1}
+	stronger := PasswordParams{Time: 2, Memory: 64, Threads: 1}
+
+	hash, err := hashPassword("secret", cheap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(hash, "$")
+	salt, key := parts[4], parts[5]
+	// withParams собирает хэш с заданной строкой параметров
+	withParams := func(version int, params string) string {
+		return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", version, params, salt, key)
+	}
+
+	tests := []struct {
+		name       string
+		hash       string
+		password   string
+		params     PasswordParams
+		wantOK     bool
+		wantRehash bool
+	}{
+		{"round trip", hash, "secret", cheap, true, false},
+		{"wrong password", hash, "Secret", cheap, false, false},
+		{"empty password", hash, "", cheap, false, false},
+		{"outdated params", hash, "secret", stronger, true, true},
+		{"bcrypt", string(bcryptHash), "secret", cheap, true, true},
+		{"bcrypt wrong password", string(bcryptHash), "other", cheap, false, true},
+		{"empty hash", "", "secret", cheap, false, false},
+		{"other algorithm", strings.Replace(hash, "argon2id", "argon2i", 1), "secret", cheap, false, false},
+		{"too few parts", "$argon2id$v=19$m=64,t=1,p=1$" + salt, "secret", cheap, false, false},
+		{"bad version", withParams(argon2.Version-1, "m=64,t=1,p=1"), "secret", cheap, false, false},
+		{"bad params syntax", withParams(argon2.Version, "m=64;t=1;p=1"), "secret", cheap, false, false},
+		{"zero time", withParams(argon2.Version, "m=64,t=0,p=1"), "secret", cheap, false, false},
+		{"too many passes", withParams(argon2.Version, "m=64,t=1000,p=1"), "secret", cheap, false, false},
+		{"too much memory", withParams(argon2.Version, "m=4194304,t=1,p=1"), "secret", cheap, false, false},
+		{"memory below threads", withParams(argon2.Version, "m=8,t=1,p=4"), "secret", cheap, false, false},
+		{"zero threads", withParams(argon2.Version, "m=64,t=1,p=0"), "secret", cheap, false, false},
+		{"short salt", fmt.Sprintf("$argon2id$v=%d$m=64,t=1,p=1$%s$%s", argon2.Version, "c2FsdA", key), "secret", cheap, false, false},
+		{"short key", fmt.Sprintf("$argon2id$v=%d$m=64,t=1,p=1$%s$%s", argon2.Version, salt, "a2V5"), "secret", cheap, false, false},
+		{"bad base64", fmt.Sprintf("$argon2id$v=%d$m=64,t=1,p=1$%s$%s", argon2.Version, salt, "!!"), "secret", cheap, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash := checkPassword(tt.hash, tt.password, tt.params)
+			if ok != tt.wantOK || rehash != tt.wantRehash {
+				t.Errorf("checkPassword = %v, %v; want %v, %v", ok, rehash, tt.wantOK, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestSetEmptyPassword(t *testing.T) {
+	// пустой пароль отвергается до обращения к БД
+	var s Storage
+	if err := s.SetPassword(context.Background(), 1, ""); !errors.Is(err, ErrEmptyPassword) {
+		t.Errorf("SetPassword(\"\") = %v, want ErrEmptyPassword", err)
+	}
+}
+
+func TestHashPasswordFormat(t *testing.T) {
+	p := PasswordParams{Time: 1, Memory: 64, Threads: 1}
+	a, err := hashPassword("secret", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := hashPassword("secret", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := fmt.Sprintf("$argon2id$v=%d$m=64,t=1,p=1$", argon2.Version)
+	if !strings.HasPrefix(a, prefix) {
+		t.Errorf("hash %q does not start with %q", a, prefix)
+	}
+	if a == b {
+		t.Error("hashes of the same password are equal: salt is not random")
+	}
+}
+
+func TestPasswordParamsValid(t *testing.T) {
+	tests := []struct {
+		p    PasswordParams
+		want bool
+	}{
+		{DefaultPasswordParams, true},
+		{PasswordParams{Time: 1, Memory: 8, Threads: 1}, true},
+		{PasswordParams{Time: maxHashTime, Memory: maxHashMemory, Threads: maxHashThreads}, true},
+		{PasswordParams{Time: 0, Memory: 64, Threads: 1}, false},
+		{PasswordParams{Time: maxHashTime + 1, Memory: 64, Threads: 1}, false},
+		{PasswordParams{Time: 1, Memory: maxHashMemory + 1, Threads: 1}, false},
+		{PasswordParams{Time: 1, Memory: 7, Threads: 1}, false},
+		{PasswordParams{Time: 1, Memory: 64, Threads: 0}, false},
+		{PasswordParams{Time: 1, Memory: 1024, Threads: maxHashThreads + 1}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.p.valid(); got != tt.want {
+			t.Errorf("%+v.valid() = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
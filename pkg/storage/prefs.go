@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrInvalidPrefs возвращается при неизвестном канале или частоте сводки.
+var ErrInvalidPrefs = errors.New("invalid notification preferences")
+
+// Канал доставки уведомлений.
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSlack Channel = "slack"
+	ChannelNone  Channel = "none" // уведомления отключены
+)
+
+// Частота сводки уведомлений.
+type Digest string
+
+const (
+	DigestNone   Digest = "none" // каждое уведомление отправляется сразу
+	DigestDaily  Digest = "daily"
+	DigestWeekly Digest = "weekly"
+)
+
+// Настройки уведомлений пользователя.
+type NotificationPrefs struct {
+	UserID      int
+	Channel     Channel
+	Digest      Digest
+	MutedLabels []string
+}
+
+// Wants сообщает, нужно ли уведомлять пользователя о задаче с метками labels.
+func (p NotificationPrefs) Wants(labels []string) bool {
+	if p.Channel == ChannelNone {
+		return false
+	}
+	for _, l := range labels {
+		for _, m := range p.MutedLabels {
+			if l == m {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NotificationPrefs возвращает настройки уведомлений пользователя;
+// если они не заданы - настройки по умолчанию: почта без сводки.
+func (s *Storage) NotificationPrefs(ctx context.Context, userID int) (NotificationPrefs, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	p := NotificationPrefs{UserID: userID}
+	var channel, digest string
+	err := s.db.QueryRow(ctx, `
+		SELECT channel, digest, muted_labels
+		FROM notification_prefs
+		WHERE user_id = $1;
+	`,
+		userID,
+	).Scan(&channel, &digest, &p.MutedLabels)
+	if errors.Is(err, pgx.ErrNoRows) {
+		p.Channel, p.Digest = ChannelEmail, DigestNone
+		return p, nil
+	}
+	if err != nil {
+		return NotificationPrefs{}, err
+	}
+	p.Channel, p.Digest = Channel(channel), Digest(digest)
+	return p, nil
+}
+
+// SetNotificationPrefs сохраняет настройки уведомлений пользователя p.UserID.
+func (s *Storage) SetNotificationPrefs(ctx context.Context, p NotificationPrefs) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	switch p.Channel {
+	case ChannelEmail, ChannelSlack, ChannelNone:
+	default:
+		return ErrInvalidPrefs
+	}
+	switch p.Digest {
+	case DigestNone, DigestDaily, DigestWeekly:
+	default:
+		return ErrInvalidPrefs
+	}
+	muted := p.MutedLabels
+	if muted == nil {
+		muted = []string{}
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO notification_prefs (user_id, channel, digest, muted_labels)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET channel = EXCLUDED.channel,
+			digest = EXCLUDED.digest,
+			muted_labels = EXCLUDED.muted_labels;
+	`,
+		p.UserID,
+		string(p.Channel),
+		string(p.Digest),
+		muted,
+	)
+	return err
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// AuditUserAnonymize - обезличивание пользователя.
+const AuditUserAnonymize = "user.anonymize"
+
+// Внешняя учётная запись пользователя.
+type Identity struct {
+	Provider string
+	Subject  string
+}
+
+// Все данные, связанные с пользователем, для выгрузки по запросу
+// субъекта персональных данных.
+type UserData struct {
+	User          User
+	Role          Role
+	Identities    []Identity
+	APIKeys       []APIKey
+	Sessions      []Session
+	Notifications NotificationPrefs
+	AuthoredTasks []Task // включая черновики
+	AssignedTasks []Task
+	Audit         []AuditEntry // действия пользователя
+}
+
+// UserByID возвращает пользователя по id или ErrUserNotFound.
+func (s *Storage) UserByID(ctx context.Context, id int) (User, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var u User
+	err := s.db.QueryRow(ctx, `SELECT id, name, email FROM users WHERE id = $1;`, id).Scan(&u.ID, &u.Name, &u.Email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+// AnonymizeUser удаляет персональные данные пользователя: имя заменяется
+// обезличенным, адрес и пароль стираются, удаляются сессии, ключи API,
+// внешние учётные записи, роль и настройки уведомлений, а из журнала
+// аудита - подробности его записей. Строка пользователя остаётся
+// обезличенным автором его задач, так что задачи и их история
+// не нарушаются.
+func (s *Storage) AnonymizeUser(ctx context.Context, userID int) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var email string
+	err = tx.QueryRow(ctx, `
+		SELECT email FROM users WHERE id = $1 FOR UPDATE;
+	`,
+		userID,
+	).Scan(&email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE users
+		SET name = $2, email = '', password_hash = ''
+		WHERE id = $1;
+	`,
+		userID,
+		"deleted user "+strconv.Itoa(userID),
+	)
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"sessions", "api_keys", "user_identities", "user_roles", "notification_prefs"} {
+		_, err = tx.Exec(ctx, `DELETE FROM `+table+` WHERE user_id = $1;`, userID)
+		if err != nil {
+			return err
+		}
+	}
+	// неудачные входы записаны без пользователя, но с его адресом
+	// в том регистре, в каком его ввели
+	_, err = tx.Exec(ctx, `
+		UPDATE audit_log
+		SET detail = '{}'
+		WHERE actor_id = $1 OR target = $2 OR ($3 <> '' AND lower(detail->>'email') = lower($3));
+	`,
+		userID,
+		target("user", userID),
+		email,
+	)
+	if err != nil {
+		return err
+	}
+	if err = addAudit(ctx, tx, AuditUserAnonymize, target("user", userID), nil); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ExportUserData возвращает все данные, связанные с пользователем.
+func (s *Storage) ExportUserData(ctx context.Context, userID int) (UserData, error) {
+	var d UserData
+	var err error
+	if d.User, err = s.UserByID(ctx, userID); err != nil {
+		return UserData{}, err
+	}
+	if d.Role, err = s.UserRole(ctx, userID); err != nil {
+		return UserData{}, err
+	}
+	if d.Identities, err = s.userIdentities(ctx, userID); err != nil {
+		return UserData{}, err
+	}
+	if d.APIKeys, err = s.APIKeys(ctx, userID); err != nil {
+		return UserData{}, err
+	}
+	if d.Sessions, err = s.userSessions(ctx, userID); err != nil {
+		return UserData{}, err
+	}
+	if d.Notifications, err = s.NotificationPrefs(ctx, userID); err != nil {
+		return UserData{}, err
+	}
+	if d.AuthoredTasks, err = s.TasksByFilter(ctx, TaskFilter{AuthorID: userID, Drafts: true, Snoozed: true}); err != nil {
+		return UserData{}, err
+	}
+	if d.AssignedTasks, err = s.TasksByFilter(ctx, TaskFilter{AssignedID: userID, Snoozed: true}); err != nil {
+		return UserData{}, err
+	}
+	if d.Audit, err = s.AuditLog(ctx, AuditFilter{ActorID: userID}); err != nil {
+		return UserData{}, err
+	}
+	return d, nil
+}
+
+// userIdentities возвращает внешние учётные записи пользователя.
+func (s *Storage) userIdentities(ctx context.Context, userID int) ([]Identity, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT provider, subject
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY provider, subject;
+	`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []Identity
+	for rows.Next() {
+		var i Identity
+		if err = rows.Scan(&i.Provider, &i.Subject); err != nil {
+			return nil, err
+		}
+		ids = append(ids, i)
+	}
+	return ids, rows.Err()
+}
+
+// userSessions возвращает активные сессии пользователя.
+func (s *Storage) userSessions(ctx context.Context, userID int) ([]Session, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT user_id, created, expires
+		FROM sessions
+		WHERE user_id = $1 AND revoked = 0 AND expires > extract(epoch from now())
+		ORDER BY created;
+	`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sessions []Session
+	for rows.Next() {
+		var sess Session
+		if err = rows.Scan(&sess.UserID, &sess.Created, &sess.Expires); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
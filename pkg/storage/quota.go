@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrQuotaExceeded возвращается, когда у автора уже есть
+// максимально допустимое число открытых задач.
+var ErrQuotaExceeded = errors.New("author open task quota exceeded")
+
+// ключ рекомендательной блокировки квоты, второй ключ - id автора
+const quotaLockKey = 387
+
+// checkQuota проверяет квоту открытых задач автора после того, как
+// транзакция tx создала или опубликовала его задачу. Блокировка автора
+// до конца транзакции гарантирует, что параллельные транзакции
+// не превысят квоту, не видя незафиксированных задач друг друга.
+func (s *Storage) checkQuota(ctx context.Context, tx pgx.Tx, authorID int) error {
+	if s.cfg.authorQuota <= 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1, $2);`, quotaLockKey, authorID)
+	if err != nil {
+		return err
+	}
+	var open int
+	err = tx.QueryRow(ctx, `
+		SELECT count(*) FROM tasks
+		WHERE author_id = $1 AND coalesce(closed, 0) = 0 AND NOT draft;
+		`,
+		authorID,
+	).Scan(&open)
+	if err != nil {
+		return err
+	}
+	if open > s.cfg.authorQuota {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrForbidden возвращается CheckPermission, если у пользователя
+// нет права на действие.
+var ErrForbidden = errors.New("permission denied")
+
+// ErrUnknownRole возвращается при назначении несуществующей роли.
+var ErrUnknownRole = errors.New("unknown role")
+
+// Роль пользователя.
+type Role string
+
+const (
+	RoleAdmin   Role = "admin"   // все действия, включая назначение ролей и обслуживание
+	RoleManager Role = "manager" // все действия с задачами
+	RoleMember  Role = "member"  // создание задач, изменение своих и назначенных
+	RoleViewer  Role = "viewer"  // только чтение, роль по умолчанию
+)
+
+// Действие, право на которое проверяет CheckPermission.
+type Action string
+
+const (
+	ActionRead        Action = "read"
+	ActionCreate      Action = "create"
+	ActionUpdate      Action = "update"
+	ActionDelete      Action = "delete"
+	ActionManageRoles Action = "manage_roles"
+	ActionMaintain    Action = "maintain"     // обслуживание БД
+	ActionManageRules Action = "manage_rules" // правила назначения
+)
+
+// SetRole назначает пользователю роль и записывает это в журнал аудита.
+func (s *Storage) SetRole(ctx context.Context, userID int, role Role) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	switch role {
+	case RoleAdmin, RoleManager, RoleMember, RoleViewer:
+	default:
+		return ErrUnknownRole
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO user_roles (user_id, role)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET role = EXCLUDED.role;
+	`,
+		userID,
+		string(role),
+	)
+	if err != nil {
+		return err
+	}
+	err = addAudit(ctx, tx, AuditRoleChange, target("user", userID), map[string]any{"role": role})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// UserRole возвращает роль пользователя; без назначенной роли - RoleViewer.
+func (s *Storage) UserRole(ctx context.Context, userID int) (Role, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var role string
+	err := s.db.QueryRow(ctx, `SELECT role FROM user_roles WHERE user_id = $1;`, userID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return RoleViewer, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return Role(role), nil
+}
+
+// CheckPermission проверяет право пользователя userID на действие action
+// с задачей taskID (0 - действие не относится к конкретной задаче)
+// и возвращает ErrForbidden, если права нет.
+// Участник (member) может изменять задачи, автором или ответственным
+// за которые он является, и удалять только свои задачи.
+// Если задачи taskID нет, возвращается ErrTaskNotFound.
+func (s *Storage) CheckPermission(ctx context.Context, userID int, action Action, taskID int) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var role string
+	var authorID, assignedID int
+	var found bool
+	err := s.db.QueryRow(ctx, `
+		SELECT
+			coalesce((SELECT role FROM user_roles WHERE user_id = $1), 'viewer'),
+			coalesce(t.author_id, 0),
+			coalesce(t.assigned_id, 0),
+			t.id IS NOT NULL
+		FROM (SELECT 1) one
+		LEFT JOIN tasks t ON t.id = $2;
+	`,
+		userID,
+		taskID,
+	).Scan(&role, &authorID, &assignedID, &found)
+	if err != nil {
+		return err
+	}
+	if taskID != 0 && !found {
+		return ErrTaskNotFound
+	}
+	if allowed(Role(role), action, found && userID == authorID, found && userID == assignedID) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// allowed - матрица прав ролей.
+func allowed(role Role, action Action, author, assignee bool) bool {
+	switch role {
+	case RoleAdmin:
+		return true
+	case RoleManager:
+		return action != ActionManageRoles && action != ActionMaintain
+	case RoleMember:
+		switch action {
+		case ActionRead, ActionCreate:
+			return true
+		case ActionUpdate:
+			return author || assignee
+		case ActionDelete:
+			return author
+		}
+		return false
+	case RoleViewer:
+		return action == ActionRead
+	}
+	return false
+}
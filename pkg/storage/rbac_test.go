@@ -0,0 +1,54 @@
+package storage
+
+import "testing"
+
+func TestAllowed(t *testing.T) {
+	actions := []Action{
+		ActionRead, ActionCreate, ActionUpdate, ActionDelete,
+		ActionManageRoles, ActionMaintain, ActionManageRules,
+	}
+	// разрешённые действия роли для чужой задачи, автора и ответственного
+	tests := []struct {
+		role     Role
+		other    []Action
+		author   []Action
+		assignee []Action
+	}{
+		{RoleAdmin, actions, actions, actions},
+		{
+			RoleManager,
+			[]Action{ActionRead, ActionCreate, ActionUpdate, ActionDelete, ActionManageRules},
+			[]Action{ActionRead, ActionCreate, ActionUpdate, ActionDelete, ActionManageRules},
+			[]Action{ActionRead, ActionCreate, ActionUpdate, ActionDelete, ActionManageRules},
+		},
+		{
+			RoleMember,
+			[]Action{ActionRead, ActionCreate},
+			[]Action{ActionRead, ActionCreate, ActionUpdate, ActionDelete},
+			[]Action{ActionRead, ActionCreate, ActionUpdate},
+		},
+		{RoleViewer, []Action{ActionRead}, []Action{ActionRead}, []Action{ActionRead}},
+		{"unknown", nil, nil, nil},
+	}
+	for _, tt := range tests {
+		for _, c := range []struct {
+			name             string
+			author, assignee bool
+			want             []Action
+		}{
+			{"other", false, false, tt.other},
+			{"author", true, false, tt.author},
+			{"assignee", false, true, tt.assignee},
+		} {
+			want := make(map[Action]bool)
+			for _, a := range c.want {
+				want[a] = true
+			}
+			for _, a := range actions {
+				if got := allowed(tt.role, a, c.author, c.assignee); got != want[a] {
+					t.Errorf("allowed(%s, %s, %s) = %v, want %v", tt.role, a, c.name, got, want[a])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrReplicaPosition возвращается ApplyEvents, если позиция репликации
+// приёмника не совпадает с позицией, за которой следуют события,
+// например при параллельной доставке.
+var ErrReplicaPosition = errors.New("replica position mismatch")
+
+// ReplicaPosition возвращает id последнего события экземпляра source,
+// применённого ApplyEvents, 0 - если событий ещё не было.
+func (s *Storage) ReplicaPosition(ctx context.Context, source string) (int64, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var pos int64
+	err := s.db.QueryRow(ctx, `
+		SELECT event_id FROM replica_positions WHERE source = $1;
+	`,
+		source,
+	).Scan(&pos)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return pos, err
+}
+
+// ApplyEvents применяет события outbox экземпляра source к задачам
+// этого экземпляра в одной транзакции и возвращает новую позицию
+// репликации - id последнего события. События должны следовать в ленте
+// источника (EventsAfter) за событием after; если позиция приёмника
+// другая, ничего не применяется и возвращается ErrReplicaPosition,
+// поэтому повторная и параллельная доставка безопасны. Сравнивать id
+// событий с позицией нельзя: id в ленте не обязательно возрастают.
+// Созданные и изменённые задачи сохраняются с исходными id, удалённые
+// удаляются, метки задач заменяются метками из события с теми же
+// названиями; события неизвестных типов пропускаются. Авторы
+// и ответственные должны существовать на приёмнике (см. ImportDirectory),
+// ссылки на отсутствующие эпики сбрасываются. Применённые изменения
+// не попадают в outbox приёмника.
+func (s *Storage) ApplyEvents(ctx context.Context, source string, after int64, events []Event) (int64, error) {
+	if err := s.checkWritable(); err != nil {
+		return 0, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// блокировка позиции выстраивает параллельные доставки в очередь
+	var pos int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO replica_positions AS p (source) VALUES ($1)
+		ON CONFLICT (source) DO UPDATE SET source = p.source
+		RETURNING p.event_id;
+	`,
+		source,
+	).Scan(&pos)
+	if err != nil {
+		return 0, err
+	}
+	if pos != after {
+		return 0, ErrReplicaPosition
+	}
+	if len(events) == 0 {
+		return pos, nil
+	}
+	for _, e := range events {
+		if err = applyEvent(ctx, tx, e); err != nil {
+			return 0, err
+		}
+		pos = e.ID
+	}
+	_, err = tx.Exec(ctx, `
+		SELECT setval(pg_get_serial_sequence('tasks', 'id'), greatest(max(id), 1))
+		FROM tasks;
+	`)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE replica_positions
+		SET event_id = $2, updated = extract(epoch from now())
+		WHERE source = $1;
+	`,
+		source,
+		pos,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return pos, nil
+}
+
+// applyEvent применяет одно событие outbox в транзакции tx.
+func applyEvent(ctx context.Context, tx pgx.Tx, e Event) error {
+	switch e.Type {
+	case EventTaskCreated, EventTaskUpdated, EventTaskClosed:
+	case EventTaskDeleted:
+		if _, err := tx.Exec(ctx, `DELETE FROM tasks_labels WHERE task_id = $1;`, e.TaskID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM tasks WHERE id = $1;`, e.TaskID)
+		return err
+	case EventTaskLabels:
+		return applyLabels(ctx, tx, e)
+	default:
+		return nil
+	}
+	var t Task
+	if err := json.Unmarshal(e.Payload, &t); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO tasks AS t (
+			id, opened, closed, due, priority, estimate, epic_id,
+			author_id, assigned_id, title, content, draft, snoozed, uid
+		)
+		VALUES (
+			$1, $2, $3, $4, $5, $6, (SELECT id FROM epics WHERE id = $7),
+			$8, $9, $10, $11, $12, $13, coalesce(nullif($14, ''), gen_random_uuid()::text)
+		)
+		ON CONFLICT (id) DO UPDATE SET
+			opened = EXCLUDED.opened,
+			closed = EXCLUDED.closed,
+			due = EXCLUDED.due,
+			priority = EXCLUDED.priority,
+			estimate = EXCLUDED.estimate,
+			epic_id = EXCLUDED.epic_id,
+			author_id = EXCLUDED.author_id,
+			assigned_id = EXCLUDED.assigned_id,
+			title = EXCLUDED.title,
+			content = EXCLUDED.content,
+			draft = EXCLUDED.draft,
+			snoozed = EXCLUDED.snoozed,
+			uid = EXCLUDED.uid;
+	`,
+		t.ID,
+		t.Opened,
+		t.Closed,
+		t.Due,
+		t.Priority,
+		t.Estimate,
+		t.EpicID,
+		t.AuthorID,
+		t.AssignedID,
+		t.Title,
+		t.Content,
+		t.Draft,
+		t.Snoozed,
+		t.UID,
+	)
+	return err
+}
+
+// applyLabels заменяет метки задачи метками из события EventTaskLabels,
+// создавая отсутствующие метки.
+func applyLabels(ctx context.Context, tx pgx.Tx, e Event) error {
+	var tl TaskLabels
+	if err := json.Unmarshal(e.Payload, &tl); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		DELETE FROM tasks_labels
+		WHERE task_id = $1 AND label_id NOT IN (
+			SELECT id FROM labels WHERE name = ANY($2)
+		);
+	`,
+		e.TaskID,
+		tl.Labels,
+	)
+	if err != nil {
+		return err
+	}
+	if len(tl.Labels) == 0 {
+		return nil
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO labels (name)
+		SELECT unnest($1::text[])
+		ON CONFLICT (name) DO NOTHING;
+	`,
+		tl.Labels,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO tasks_labels (task_id, label_id)
+		SELECT t.id, l.id FROM tasks t, labels l
+		WHERE t.id = $1 AND l.name = ANY($2)
+		ON CONFLICT DO NOTHING;
+	`,
+		e.TaskID,
+		tl.Labels,
+	)
+	return err
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaVersion - версия схемы БД (schema.sql), с которой работает пакет.
+const SchemaVersion = 4
+
+// ErrSchemaMismatch возвращается CheckSchema, если схема БД
+// не соответствует ожидаемой пакетом.
+var ErrSchemaMismatch = errors.New("database schema mismatch")
+
+// Таблицы и их столбцы, к которым обращается пакет.
+var schemaTables = map[string][]string{
+	"users":              {"id", "name", "email", "password_hash", "time_zone"},
+	"user_roles":         {"user_id", "role"},
+	"api_keys":           {"id", "user_id", "name", "prefix", "hash", "scopes", "created", "last_used", "revoked"},
+	"notification_prefs": {"user_id", "channel", "digest", "muted_labels"},
+	"user_identities":    {"provider", "subject", "user_id"},
+	"sessions":           {"hash", "user_id", "created", "expires", "revoked"},
+	"labels":             {"id", "name"},
+	"epics":              {"id", "opened", "closed", "title", "description"},
+	"tasks": {"id", "opened", "closed", "updated", "due", "priority", "estimate", "epic_id",
+		"author_id", "assigned_id", "title", "content", "draft", "snoozed", "uid"},
+	"tasks_labels":      {"task_id", "label_id"},
+	"label_stats":       {"label_id", "open_count", "closed_count"},
+	"checklist_items":   {"id", "task_id", "text", "done", "position"},
+	"task_stars":        {"user_id", "task_id", "created"},
+	"assignment_rules":  {"id", "position", "label", "epic_id", "assignees", "strategy", "cursor"},
+	"outbox":            {"id", "created", "type", "task_id", "payload", "published", "xid"},
+	"jobs":              {"id", "kind", "params", "user_id", "status", "done", "total", "error", "result", "result_type", "attempts", "created", "heartbeat", "finished"},
+	"replica_positions": {"source", "event_id", "updated"},
+	"audit_log":         {"id", "created", "actor_id", "action", "target", "detail"},
+	"schema_version":    {"version"},
+	"task_summaries": {"id", "opened", "closed", "updated", "author_id", "author_name",
+		"assigned_id", "title", "draft", "snoozed", "epic_id", "labels"},
+}
+
+// Индексы, на которые рассчитаны запросы пакета: уникальные индексы
+// нужны для ON CONFLICT, остальные - для приемлемой скорости.
+var schemaIndexes = []string{
+	"users_email_idx",
+	"api_keys_user_id_idx",
+	"user_identities_user_id_idx",
+	"sessions_user_id_idx",
+	"sessions_expires_idx",
+	"tasks_uid_idx",
+	"tasks_due_idx",
+	"tasks_updated_idx",
+	"tasks_opened_idx",
+	"tasks_closed_idx",
+	"tasks_title_trgm_idx",
+	"tasks_triage_idx",
+	"tasks_epic_id_idx",
+	"tasks_snoozed_idx",
+	"checklist_items_task_id_idx",
+	"task_stars_task_id_idx",
+	"outbox_unpublished_idx",
+	"outbox_xid_idx",
+	"jobs_pending_idx",
+	"audit_log_created_idx",
+	"audit_log_actor_id_idx",
+}
+
+// Состояние схемы БД.
+type SchemaStatus struct {
+	Version  int      `json:"version"`           // версия схемы БД, 0 - не задана
+	Expected int      `json:"expected"`          // SchemaVersion
+	Missing  []string `json:"missing,omitempty"` // отсутствующие таблицы, столбцы и индексы
+}
+
+// Err возвращает ErrSchemaMismatch с перечнем расхождений
+// или nil, если схема совместима.
+func (st SchemaStatus) Err() error {
+	if len(st.Missing) > 0 {
+		return fmt.Errorf("%w: missing %s", ErrSchemaMismatch, strings.Join(st.Missing, ", "))
+	}
+	if st.Version == 0 {
+		return fmt.Errorf("%w: schema version is not set", ErrSchemaMismatch)
+	}
+	if st.Version != st.Expected {
+		return fmt.Errorf("%w: version %d, expected %d", ErrSchemaMismatch, st.Version, st.Expected)
+	}
+	return nil
+}
+
+// CheckSchema проверяет, что в БД есть все таблицы, столбцы и индексы,
+// к которым обращается пакет, и что версия схемы равна SchemaVersion.
+// При расхождении возвращается ошибка ErrSchemaMismatch с перечнем
+// отсутствующих объектов, чтобы несовместимая БД обнаруживалась
+// при запуске сервиса, а не ошибками отдельных запросов.
+func (s *Storage) CheckSchema(ctx context.Context) error {
+	st, err := s.SchemaStatus(ctx)
+	if err != nil {
+		return err
+	}
+	return st.Err()
+}
+
+// SchemaStatus возвращает версию схемы БД и перечень таблиц, столбцов
+// и индексов, к которым обращается пакет, но которых в БД нет.
+func (s *Storage) SchemaStatus(ctx context.Context) (SchemaStatus, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	st := SchemaStatus{Expected: SchemaVersion}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT c.relname, a.attname
+		FROM pg_class c
+		JOIN pg_attribute a ON a.attrelid = c.oid
+		WHERE
+			c.relnamespace = current_schema()::regnamespace AND
+			c.relkind IN ('r', 'p') AND
+			a.attnum > 0 AND NOT a.attisdropped;
+	`)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	found := make(map[string]map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err = rows.Scan(&table, &column); err != nil {
+			rows.Close()
+			return SchemaStatus{}, err
+		}
+		if found[table] == nil {
+			found[table] = make(map[string]bool)
+		}
+		found[table][column] = true
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return SchemaStatus{}, err
+	}
+	tables := make([]string, 0, len(schemaTables))
+	for table := range schemaTables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		if found[table] == nil {
+			st.Missing = append(st.Missing, "table "+table)
+			continue
+		}
+		for _, column := range schemaTables[table] {
+			if !found[table][column] {
+				st.Missing = append(st.Missing, "column "+table+"."+column)
+			}
+		}
+	}
+
+	var missing []string
+	err = s.db.QueryRow(ctx, `
+		SELECT coalesce(array_agg(name ORDER BY name), '{}')
+		FROM unnest($1::text[]) name
+		WHERE NOT EXISTS (
+			SELECT 1 FROM pg_indexes
+			WHERE schemaname = current_schema() AND indexname = name
+		);
+	`,
+		schemaIndexes,
+	).Scan(&missing)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	for _, name := range missing {
+		st.Missing = append(st.Missing, "index "+name)
+	}
+
+	if found["schema_version"]["version"] {
+		err = s.db.QueryRow(ctx, `SELECT coalesce(max(version), 0) FROM schema_version;`).Scan(&st.Version)
+		if err != nil {
+			return SchemaStatus{}, err
+		}
+	}
+	return st, nil
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"strings"
+)
+
+// likeEscaper экранирует спецсимволы шаблона LIKE.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// TasksByTitle возвращает задачи, заголовок которых содержит pattern
+// без учёта регистра. Символы % и _ в pattern ищутся буквально.
+// Задачи, заголовок которых начинается с pattern, идут первыми.
+// Возвращается не больше limit задач; 0 - без ограничения.
+func (s *Storage) TasksByTitle(ctx context.Context, pattern string, limit int) ([]Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	escaped := likeEscaper.Replace(pattern)
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE t.title ILIKE '%' || $1 || '%' AND NOT t.draft
+		ORDER BY t.title ILIKE $1 || '%' DESC, t.id
+		LIMIT nullif($2, 0);
+	`,
+		escaped,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows, 0)
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrInvalidSession возвращается для неизвестной, истёкшей
+// или завершённой сессии.
+var ErrInvalidSession = errors.New("invalid session")
+
+// Сессия пользователя. Токен сессии не хранится
+// и возвращается только при создании.
+type Session struct {
+	UserID  int
+	Created int64
+	Expires int64
+}
+
+// CreateSession открывает сессию пользователя userID сроком ttl
+// и возвращает её токен.
+func (s *Storage) CreateSession(ctx context.Context, userID int, ttl time.Duration) (string, error) {
+	if err := s.checkWritable(); err != nil {
+		return "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	hash := sha256.Sum256([]byte(token))
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO sessions (hash, user_id, expires)
+		VALUES ($1, $2, $3);
+	`,
+		hash[:],
+		userID,
+		time.Now().Add(ttl).Unix(),
+	)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// SessionByToken возвращает активную сессию по токену
+// или ErrInvalidSession.
+func (s *Storage) SessionByToken(ctx context.Context, token string) (Session, error) {
+	hash := sha256.Sum256([]byte(token))
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var sess Session
+	err := s.db.QueryRow(ctx, `
+		SELECT user_id, created, expires
+		FROM sessions
+		WHERE hash = $1 AND revoked = 0 AND expires > extract(epoch from now());
+	`,
+		hash[:],
+	).Scan(&sess.UserID, &sess.Created, &sess.Expires)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Session{}, ErrInvalidSession
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	return sess, nil
+}
+
+// RevokeSession завершает сессию с токеном token.
+// Завершение неизвестной сессии не считается ошибкой.
+func (s *Storage) RevokeSession(ctx context.Context, token string) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(token))
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	_, err := s.db.Exec(ctx, `
+		UPDATE sessions SET revoked = extract(epoch from now())
+		WHERE hash = $1 AND revoked = 0;
+	`,
+		hash[:],
+	)
+	return err
+}
+
+// RevokeUserSessions завершает все сессии пользователя,
+// например после смены пароля. Возвращает число завершённых сессий.
+func (s *Storage) RevokeUserSessions(ctx context.Context, userID int) (int, error) {
+	if err := s.checkWritable(); err != nil {
+		return 0, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tag, err := s.db.Exec(ctx, `
+		UPDATE sessions SET revoked = extract(epoch from now())
+		WHERE user_id = $1 AND revoked = 0 AND expires > extract(epoch from now());
+	`,
+		userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// DeleteExpiredSessions удаляет истёкшие и завершённые сессии
+// и возвращает их число.
+func (s *Storage) DeleteExpiredSessions(ctx context.Context) (int, error) {
+	if err := s.checkWritable(); err != nil {
+		return 0, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM sessions
+		WHERE expires <= extract(epoch from now()) OR revoked <> 0;
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
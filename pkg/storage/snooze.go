@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Snooze откладывает задачу до момента until: до этого времени она
+// не видна в списках TasksByFilter без признака Snoozed и затем
+// возвращается в них сама: фильтр сравнивает время с now() при каждом
+// запросе, и фонового задания для возврата не нужно. Напоминания
+// о сроке отложенной задачи тоже не приходят до её возвращения.
+// Нулевое until отменяет откладывание.
+func (s *Storage) Snooze(ctx context.Context, taskID int, until time.Time) (Task, error) {
+	if err := s.checkWritable(); err != nil {
+		return Task{}, err
+	}
+	var snoozed int64
+	if !until.IsZero() {
+		snoozed = until.Unix()
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Task{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := scanTask(tx.QueryRow(ctx, `
+		UPDATE tasks AS t SET snoozed = $2
+		WHERE t.id = $1
+		RETURNING `+taskColumns+`;
+		`,
+		taskID,
+		snoozed,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Task{}, ErrTaskNotFound
+	}
+	if err != nil {
+		return Task{}, err
+	}
+	if err = addEvent(ctx, tx, EventTaskUpdated, t); err != nil {
+		return Task{}, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return Task{}, err
+	}
+	return t, nil
+}
+
+// SnoozedTasks возвращает открытые задачи, отложенные на данный момент,
+// в порядке времени их возвращения в списки. Если assignee не 0,
+// выбираются только задачи этого ответственного.
+func (s *Storage) SnoozedTasks(ctx context.Context, assignee int) ([]Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE
+			t.snoozed > extract(epoch from now()) AND
+			t.closed = 0 AND
+			($1 = 0 OR t.assigned_id = $1) AND
+			NOT t.draft
+		ORDER BY t.snoozed, t.id;
+	`,
+		assignee,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows, 0)
+}
@@ -0,0 +1,70 @@
+package storage
+
+import "context"
+
+// StarTask отмечает задачу звёздочкой пользователя userID.
+// Повторная отметка не считается ошибкой. Если задачи нет,
+// возвращается ErrTaskNotFound.
+func (s *Storage) StarTask(ctx context.Context, userID, taskID int) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var found bool
+	err := s.db.QueryRow(ctx, `
+		WITH star AS (
+			INSERT INTO task_stars (user_id, task_id)
+			SELECT $1, t.id FROM tasks t WHERE t.id = $2
+			ON CONFLICT DO NOTHING
+		)
+		SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $2);
+	`,
+		userID,
+		taskID,
+	).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+// UnstarTask снимает отметку пользователя userID с задачи.
+// Снятие отсутствующей отметки не считается ошибкой.
+func (s *Storage) UnstarTask(ctx context.Context, userID, taskID int) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	_, err := s.db.Exec(ctx, `
+		DELETE FROM task_stars WHERE user_id = $1 AND task_id = $2;
+	`,
+		userID,
+		taskID,
+	)
+	return err
+}
+
+// StarredTasks возвращает задачи, отмеченные пользователем userID,
+// начиная с отмеченных последними.
+func (s *Storage) StarredTasks(ctx context.Context, userID int) ([]Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM task_stars ts
+		JOIN tasks t ON t.id = ts.task_id
+		WHERE ts.user_id = $1 AND NOT t.draft
+		ORDER BY ts.created DESC, t.id;
+	`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows, 0)
+}
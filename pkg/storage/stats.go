@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Выполненная за неделю работа.
+type WeekVelocity struct {
+	Week   time.Time // начало недели (понедельник)
+	Points int       // сумма оценок выполненных задач
+	Tasks  int       // число выполненных задач
+}
+
+// Velocity возвращает сумму оценок задач, выполненных за последние window,
+// по неделям в хронологическом порядке. Если assignee не 0, учитываются
+// только задачи этого ответственного, иначе - задачи всей команды.
+// Недели без выполненных задач в результат не попадают.
+func (s *Storage) Velocity(ctx context.Context, assignee int, window time.Duration) ([]WeekVelocity, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT
+			date_trunc('week', to_timestamp(t.closed)) AS week,
+			sum(t.estimate),
+			count(*)
+		FROM tasks t
+		WHERE
+			t.closed >= $1 AND
+			($2 = 0 OR t.assigned_id = $2)
+		GROUP BY week
+		ORDER BY week;
+	`,
+		time.Now().Add(-window).Unix(),
+		assignee,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var weeks []WeekVelocity
+	for rows.Next() {
+		var w WeekVelocity
+		if err = rows.Scan(&w.Week, &w.Points, &w.Tasks); err != nil {
+			return nil, err
+		}
+		weeks = append(weeks, w)
+	}
+	return weeks, rows.Err()
+}
+
+// Статистика времени выполнения задач (от создания до закрытия).
+type CycleTime struct {
+	Count int // число выполненных задач
+	Min   time.Duration
+	Avg   time.Duration
+	P50   time.Duration // медиана
+	P95   time.Duration
+}
+
+// CycleTimeStats вычисляет на сервере БД статистику времени выполнения
+// задач, подходящих под фильтр. Учитываются только выполненные задачи,
+// включая отложенные.
+func (s *Storage) CycleTimeStats(ctx context.Context, f TaskFilter) (CycleTime, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	f.Snoozed = true
+	where, args := f.where(nil)
+	var (
+		ct            CycleTime
+		minSec        int64
+		avg, p50, p95 float64
+	)
+	err := s.db.QueryRow(ctx, `
+		SELECT
+			count(*),
+			coalesce(min(d), 0),
+			coalesce(avg(d), 0)::float8,
+			coalesce(percentile_cont(0.5) WITHIN GROUP (ORDER BY d), 0),
+			coalesce(percentile_cont(0.95) WITHIN GROUP (ORDER BY d), 0)
+		FROM (
+			SELECT t.closed - t.opened AS d
+			FROM tasks t
+			WHERE `+where+` AND t.closed <> 0
+		) cycle;
+		`,
+		args...,
+	).Scan(&ct.Count, &minSec, &avg, &p50, &p95)
+	if err != nil {
+		return CycleTime{}, err
+	}
+	ct.Min = time.Duration(minSec) * time.Second
+	ct.Avg = time.Duration(avg * float64(time.Second))
+	ct.P50 = time.Duration(p50 * float64(time.Second))
+	ct.P95 = time.Duration(p95 * float64(time.Second))
+	return ct, nil
+}
@@ -2,7 +2,13 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
@@ -16,50 +22,197 @@ DONE Обновлять задачу по id, - func UpdateTask
 DONE Удалять задачу по id. - func DeleteTask
 */
 
+// ErrTaskNotFound возвращается, когда задача с указанным id отсутствует.
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrReadOnly возвращается изменяющими методами хранилища,
+// созданного с опцией WithReadOnly.
+var ErrReadOnly = errors.New("storage is read-only")
+
+// ErrInvalidTask возвращается, когда у публикуемой задачи
+// не заполнены обязательные поля.
+var ErrInvalidTask = errors.New("task title is required")
+
 // Хранилище данных.
 type Storage struct {
-	db *pgxpool.Pool
+	db  pool
+	cfg config
+
+	// места для одновременного вычисления хэшей паролей
+	hashing chan struct{}
+
+	// размер последнего полного списка задач,
+	// используется для предварительного выделения памяти
+	tasksHint atomic.Int64
 }
 
-// Конструктор, принимает строку подключения к БД.
-func New(constr string) (*Storage, error) {
-	db, err := pgxpool.Connect(context.Background(), constr)
+// Конструктор, принимает строку подключения к БД и опции.
+func New(constr string, opts ...Option) (*Storage, error) {
+	cfg := config{passwordParams: DefaultPasswordParams}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	poolCfg, err := pgxpool.ParseConfig(constr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.queryTimeout > 0 {
+		poolCfg.ConnConfig.RuntimeParams["statement_timeout"] = statementTimeout(cfg.queryTimeout)
+	}
+	st := newStatementTimeouts(cfg.queryTimeout)
+	poolCfg.BeforeAcquire = st.beforeAcquire
+	poolCfg.AfterRelease = st.afterRelease
+	if cfg.readOnly {
+		poolCfg.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+	}
+	poolCfg.LazyConnect = cfg.lazyConnect
+	if cfg.logger != nil || cfg.slowQuery > 0 {
+		poolCfg.ConnConfig.Logger = queryLogger{cfg.logger, cfg.slowQuery}
+		poolCfg.ConnConfig.LogLevel = pgx.LogLevelInfo
+	}
+	db, err := connect(poolCfg, cfg.connectAttempts, cfg.connectBackoff)
 	if err != nil {
 		return nil, err
 	}
-	s := Storage{
-		db: db,
+	s := &Storage{
+		db:      pool{db},
+		cfg:     cfg,
+		hashing: make(chan struct{}, runtime.GOMAXPROCS(0)),
+	}
+	if cfg.checkSchema && !cfg.lazyConnect {
+		if err = s.CheckSchema(context.Background()); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// checkWritable возвращает ErrReadOnly, если хранилище только для чтения.
+func (s *Storage) checkWritable() error {
+	if s.cfg.readOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// connect подключается к БД, повторяя неудачные попытки
+// с удваивающейся паузой, всего не более attempts раз.
+func connect(poolCfg *pgxpool.Config, attempts int, backoff time.Duration) (*pgxpool.Pool, error) {
+	for i := 1; ; i++ {
+		db, err := pgxpool.ConnectConfig(context.Background(), poolCfg)
+		if err == nil || i >= attempts {
+			return db, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
 	}
-	return &s, nil
 }
 
 // Задача.
 type Task struct {
-	ID         int
-	Opened     int64
-	Closed     int64
-	AuthorID   int
-	AssignedID int
-	Title      string
-	Content    string
+	ID         int    `json:"id"`
+	Opened     int64  `json:"opened"`
+	Closed     int64  `json:"closed"`
+	Updated    int64  `json:"updated"` // время последнего изменения
+	Due        int64  `json:"due"`
+	Priority   int    `json:"priority"`
+	Estimate   int    `json:"estimate"` // оценка в story points
+	AuthorID   int    `json:"author_id"`
+	AssignedID int    `json:"assigned_id"`
+	EpicID     int    `json:"epic_id"` // 0 - задача вне эпика
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Draft      bool   `json:"draft"`
+	Snoozed    int64  `json:"snoozed"` // отложена до этого времени, 0 - не отложена
+	UID        string `json:"uid"`     // глобальный идентификатор
+}
+
+// validate проверяет обязательные поля задачи.
+// Черновики не проверяются до публикации.
+func (t Task) validate() error {
+	if strings.TrimSpace(t.Title) == "" {
+		return ErrInvalidTask
+	}
+	return nil
+}
+
+// Столбцы задачи в порядке, ожидаемом scanTask.
+// Таблица tasks в запросах должна иметь псевдоним t.
+const taskColumns = `
+	t.id,
+	t.opened,
+	t.closed,
+	t.updated,
+	t.due,
+	t.priority,
+	t.estimate,
+	t.author_id,
+	t.assigned_id,
+	coalesce(t.epic_id, 0),
+	coalesce(t.title, ''),
+	coalesce(t.content, ''),
+	t.draft,
+	t.snoozed,
+	t.uid`
+
+// dest возвращает указатели на поля задачи в порядке столбцов taskColumns.
+func (t *Task) dest() []any {
+	return []any{
+		&t.ID,
+		&t.Opened,
+		&t.Closed,
+		&t.Updated,
+		&t.Due,
+		&t.Priority,
+		&t.Estimate,
+		&t.AuthorID,
+		&t.AssignedID,
+		&t.EpicID,
+		&t.Title,
+		&t.Content,
+		&t.Draft,
+		&t.Snoozed,
+		&t.UID,
+	}
+}
+
+// scanTask сканирует строку со столбцами taskColumns.
+func scanTask(row pgx.Row) (Task, error) {
+	var t Task
+	err := row.Scan(t.dest()...)
+	return t, err
+}
+
+// scanTasks сканирует все строки результата со столбцами taskColumns.
+// capacity - ожидаемое число строк для предварительного выделения памяти.
+func scanTasks(rows pgx.Rows, capacity int) ([]Task, error) {
+	defer rows.Close()
+	tasks := make([]Task, 0, capacity)
+	// итерирование по результату выполнения запроса
+	// и сканирование каждой строки сразу в элемент массива результатов
+	for rows.Next() {
+		tasks = append(tasks, Task{})
+		if err := rows.Scan(tasks[len(tasks)-1].dest()...); err != nil {
+			return nil, err
+		}
+	}
+	// ВАЖНО не забыть проверить rows.Err()
+	return tasks, rows.Err()
 }
 
 // Tasks возвращает список задач из БД.
-func (s *Storage) Tasks(taskID, authorID int) ([]Task, error) {
-	rows, err := s.db.Query(context.Background(), `
-		SELECT 
-			id,
-			opened,
-			closed,
-			author_id,
-			assigned_id,
-			title,
-			content
-		FROM tasks
+func (s *Storage) Tasks(ctx context.Context, taskID, authorID int) ([]Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
 		WHERE
-			($1 = 0 OR id = $1) AND
-			($2 = 0 OR author_id = $2)
-		ORDER BY id;
+			($1 = 0 OR t.id = $1) AND
+			($2 = 0 OR t.author_id = $2) AND
+			NOT t.draft
+		ORDER BY t.id;
 	`,
 		taskID,
 		authorID,
@@ -67,175 +220,392 @@ func (s *Storage) Tasks(taskID, authorID int) ([]Task, error) {
 	if err != nil {
 		return nil, err
 	}
-	var tasks []Task
-	// итерирование по результату выполнения запроса
-	// и сканирование каждой строки в переменную
-	for rows.Next() {
-		var t Task
-		err = rows.Scan(
-			&t.ID,
-			&t.Opened,
-			&t.Closed,
-			&t.AuthorID,
-			&t.AssignedID,
-			&t.Title,
-			&t.Content,
-		)
+	if taskID != 0 || authorID != 0 {
+		return scanTasks(rows, 0)
+	}
+	// полный список обычно близок по размеру к предыдущему
+	tasks, err := scanTasks(rows, int(s.tasksHint.Load()))
+	if err == nil {
+		s.tasksHint.Store(int64(len(tasks)))
+	}
+	return tasks, err
+}
+
+// TaskByID возвращает задачу по id, в том числе черновик.
+// Если задачи с таким id нет, возвращается ErrTaskNotFound.
+func (s *Storage) TaskByID(ctx context.Context, id int) (Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	t, err := scanTask(s.db.QueryRow(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE t.id = $1;
+	`,
+		id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Task{}, ErrTaskNotFound
+	}
+	if err != nil {
+		return Task{}, err
+	}
+	return t, nil
+}
+
+// TasksByIDs возвращает задачи с id из списка ids одним запросом
+// в порядке ids, в том числе черновики. Отсутствующие id пропускаются
+// без ErrTaskNotFound: ссылки на удалённые задачи обычно допустимы,
+// а обнаружить их можно по длине результата. Повторяющиеся id дают
+// задачу повторно.
+func (s *Storage) TasksByIDs(ctx context.Context, ids []int) ([]Task, error) {
+	if len(ids) == 0 {
+		return []Task{}, nil
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM unnest($1::integer[]) WITH ORDINALITY AS q(id, n)
+		JOIN tasks t ON t.id = q.id
+		ORDER BY q.n;
+	`,
+		ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows, len(ids))
+}
+
+// NewTask создаёт новую задачу и возвращает её вместе с присвоенными
+// значениями по умолчанию. Сохраняются все переданные поля, кроме ID;
+// нулевое время создания заменяется текущим, остальные незаданные
+// поля - значениями WithDefaults.
+// Задача с признаком Draft сохраняется как черновик без проверки полей
+// и становится видна в списках после вызова PublishTask.
+func (s *Storage) NewTask(ctx context.Context, t Task) (Task, error) {
+	return s.NewTaskWithLabels(ctx, t, nil)
+}
+
+// NewTaskWithLabels создаёт новую задачу с метками labels и возвращает её.
+// Задача и её связи с метками создаются в одной транзакции,
+// отсутствующие метки создаются автоматически. Задаче без ответственного
+// он назначается по правилам назначения, а если ни одно не подошло -
+// по умолчанию.
+func (s *Storage) NewTaskWithLabels(ctx context.Context, t Task, labels []string) (Task, error) {
+	if err := s.checkWritable(); err != nil {
+		return Task{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Task{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := s.createTask(ctx, tx, t, labels)
+	if err != nil {
+		return Task{}, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return Task{}, err
+	}
+	return created, nil
+}
+
+// createTask создаёт в транзакции tx задачу t с метками labels так же,
+// как NewTaskWithLabels: применяет значения по умолчанию, проверяет
+// поля опубликованной задачи и назначает ответственного по правилам.
+func (s *Storage) createTask(ctx context.Context, tx pgx.Tx, t Task, labels []string) (Task, error) {
+	t, labels = s.cfg.defaults.apply(t, labels)
+	if !t.Draft {
+		if err := t.validate(); err != nil {
+			return Task{}, err
+		}
+	}
+	if t.AssignedID == 0 {
+		id, err := assignByRules(ctx, tx, t, labels)
 		if err != nil {
-			return nil, err
+			return Task{}, err
 		}
-		// добавление переменной в массив результатов
-		tasks = append(tasks, t)
-
+		if id == 0 {
+			id = s.cfg.defaults.assignee(t)
+		}
+		t.AssignedID = id
 	}
-	// ВАЖНО не забыть проверить rows.Err()
-	return tasks, rows.Err()
+	created, err := s.insertTask(ctx, tx, t)
+	if err != nil {
+		return Task{}, err
+	}
+	if err = linkLabels(ctx, tx, created.ID, labels); err != nil {
+		return Task{}, err
+	}
+	return created, nil
 }
 
-// NewTask создаёт новую задачу и возвращает её id.
-func (s *Storage) NewTask(t Task) (int, error) {
-	var id int
-	err := s.db.QueryRow(context.Background(), `
-		INSERT INTO tasks (title, content)
-		VALUES ($1, $2) RETURNING id;
+// insertTask добавляет задачу в транзакции tx, проверяет квоту автора
+// и записывает событие о создании. Возвращает созданную задачу.
+// Задаче без UID он выдаётся генератором WithUIDGenerator или сервером.
+func (s *Storage) insertTask(ctx context.Context, tx pgx.Tx, t Task) (Task, error) {
+	if t.UID == "" && s.cfg.newUID != nil {
+		t.UID = s.cfg.newUID()
+	}
+	created, err := scanTask(tx.QueryRow(ctx, `
+		INSERT INTO tasks AS t (
+			title, content, draft, due, priority, estimate, epic_id,
+			author_id, assigned_id, opened, closed, uid
+		)
+		VALUES (
+			$1, $2, $3, $4, $5, $6, nullif($7, 0),
+			$8, $9, coalesce(nullif($10::bigint, 0), extract(epoch from now())::bigint), $11,
+			coalesce(nullif($12, ''), gen_random_uuid()::text)
+		)
+		RETURNING `+taskColumns+`;
 		`,
 		t.Title,
 		t.Content,
-	).Scan(&id)
-	return id, err
+		t.Draft,
+		t.Due,
+		t.Priority,
+		t.Estimate,
+		t.EpicID,
+		t.AuthorID,
+		t.AssignedID,
+		t.Opened,
+		t.Closed,
+		t.UID,
+	))
+	if isUniqueViolation(err, "tasks_uid_idx") {
+		return Task{}, ErrDuplicateUID
+	}
+	if err != nil {
+		return Task{}, err
+	}
+	// квота ограничивает только открытые опубликованные задачи
+	if !created.Draft && created.Closed == 0 {
+		if err = s.checkQuota(ctx, tx, created.AuthorID); err != nil {
+			return Task{}, err
+		}
+	}
+	if err = addEvent(ctx, tx, EventTaskCreated, created); err != nil {
+		return Task{}, err
+	}
+	return created, nil
+}
+
+// TasksByFilter возвращает задачи, подходящие под фильтр,
+// в заданном фильтром порядке.
+func (s *Storage) TasksByFilter(ctx context.Context, f TaskFilter) ([]Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	sql, args := f.query()
+	rows, err := s.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows, 0)
 }
 
 // TaskByAuthor возвращает список задач определенного автора.
-func (s *Storage) TaskByAuthor(authorID int) ([]Task, error) {
-	rows, err := s.db.Query(context.Background(), `
-		SELECT 
-			id,
-			opened,
-			closed,
-			author_id,
-			assigned_id,
-			title,
-			content
-		FROM tasks
+func (s *Storage) TaskByAuthor(ctx context.Context, authorID int) ([]Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
 		WHERE
-			(author_id = $1)
-		ORDER BY id;
+			(t.author_id = $1) AND
+			NOT t.draft
+		ORDER BY t.id;
 	`,
 		authorID,
 	)
 	if err != nil {
 		return nil, err
 	}
-	var tasks []Task
-	// итерирование по результату выполнения запроса
-	// и сканирование каждой строки в переменную
-	for rows.Next() {
-		var t Task
-		err = rows.Scan(
-			&t.ID,
-			&t.Opened,
-			&t.Closed,
-			&t.AuthorID,
-			&t.AssignedID,
-			&t.Title,
-			&t.Content,
-		)
-		if err != nil {
-			return nil, err
-		}
-		// добавление переменной в массив результатов
-		tasks = append(tasks, t)
-
-	}
-	// ВАЖНО не забыть проверить rows.Err()
-	return tasks, rows.Err()
+	return scanTasks(rows, 0)
 }
 
 // TaskByLabel возвращает список задач с соответствующей меткой.
-func (s *Storage) TaskByLabel(labelName string) ([]Task, error) {
-	rows, err := s.db.Query(context.Background(), `
-		SELECT 
-			id,
-			opened,
-			closed,
-			author_id,
-			assigned_id,
-			title,
-			content
-		FROM tasks
-		WHERE id IN (select task_id from tasks_labels where label_id in 
-			(select id from labels where name = $1)
-		ORDER BY id;
+func (s *Storage) TaskByLabel(ctx context.Context, labelName string) ([]Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE t.id IN (select task_id from tasks_labels where label_id in
+			(select id from labels where name = $1))
+			AND NOT t.draft
+		ORDER BY t.id;
 	`,
 		labelName,
 	)
 	if err != nil {
 		return nil, err
 	}
-	var tasks []Task
-	// итерирование по результату выполнения запроса
-	// и сканирование каждой строки в переменную
-	for rows.Next() {
-		var t Task
-		err = rows.Scan(
-			&t.ID,
-			&t.Opened,
-			&t.Closed,
-			&t.AuthorID,
-			&t.AssignedID,
-			&t.Title,
-			&t.Content,
-		)
-		if err != nil {
-			return nil, err
-		}
-		// добавление переменной в массив результатов
-		tasks = append(tasks, t)
-
-	}
-	// ВАЖНО не забыть проверить rows.Err()
-	return tasks, rows.Err()
+	return scanTasks(rows, 0)
 }
 
 // UpdateTask обновляет поля задачи и возвращает задачу.
-func (s *Storage) UpdateTask(taskData Task) (Task, error) {
-	var updatedTask Task
-	err := s.db.QueryRow(context.Background(), `
-			UPDATE tasks
+func (s *Storage) UpdateTask(ctx context.Context, taskData Task) (Task, error) {
+	if err := s.checkWritable(); err != nil {
+		return Task{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Task{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// прежнее значение closed определяет тип события
+	var closed int64
+	err = tx.QueryRow(ctx, `
+		SELECT coalesce(closed, 0) FROM tasks WHERE id = $1 FOR UPDATE;
+		`,
+		taskData.ID,
+	).Scan(&closed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Task{}, ErrTaskNotFound
+	}
+	if err != nil {
+		return Task{}, err
+	}
+	updatedTask, err := scanTask(tx.QueryRow(ctx, `
+			UPDATE tasks AS t
 			SET assigned_id = $1,
 				closed = $2,
 				content = $3,
-				title = $4
-			WHERE id = $5
-			RETURNING id, opened, closed, author_id, assigned_id, title, content;
+				title = $4,
+				due = $5,
+				priority = $6,
+				estimate = $7,
+				epic_id = nullif($8, 0)
+			WHERE t.id = $9
+			RETURNING `+taskColumns+`;
 			`,
 		taskData.AssignedID,
 		taskData.Closed,
 		taskData.Content,
 		taskData.Title,
+		taskData.Due,
+		taskData.Priority,
+		taskData.Estimate,
+		taskData.EpicID,
 		taskData.ID,
-	).Scan(&updatedTask.ID, &updatedTask.Opened, &updatedTask.Closed, &updatedTask.AuthorID, &updatedTask.AssignedID, &updatedTask.Title, &updatedTask.Content)
-
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Task{}, ErrTaskNotFound
+	}
 	if err != nil {
 		return Task{}, err
 	}
+	if err = addEvent(ctx, tx, updateEvent(closed, updatedTask), updatedTask); err != nil {
+		return Task{}, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return Task{}, err
+	}
 
 	return updatedTask, nil
 }
 
-// DeleteTask удаляет задачу по id.
-func (s *Storage) DeleteTask(id int) error {
+// DeleteTask удаляет задачу по id вместе с её связями с метками
+// и возвращает число удалённых строк.
+// Если задачи с таким id нет, возвращается ErrTaskNotFound.
+// В контексте WithDryRun задача не удаляется, но результат
+// показывает, что было бы удалено.
+func (s *Storage) DeleteTask(ctx context.Context, id int) (DeleteStats, error) {
+	if err := s.checkWritable(); err != nil {
+		return DeleteStats{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return DeleteStats{}, err
+	}
+	defer tx.Rollback(ctx)
 
-	_, err := s.db.Query(context.Background(), `
-			DELETE FROM tasks
-			WHERE id = $1;
-			`,
-		id,
+	stats, err := deleteTasks(ctx, tx, []int{id})
+	if err != nil {
+		return DeleteStats{}, err
+	}
+	if stats.Tasks == 0 {
+		return DeleteStats{}, ErrTaskNotFound
+	}
+	return stats, finish(ctx, tx)
+}
+
+// Drafts возвращает черновики задач автора.
+func (s *Storage) Drafts(ctx context.Context, authorID int) ([]Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE t.author_id = $1 AND t.draft
+		ORDER BY t.id;
+	`,
+		authorID,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows, 0)
+}
 
+// PublishTask публикует черновик, делая задачу видимой в списках.
+// Если обязательные поля черновика не заполнены, возвращается ErrInvalidTask.
+func (s *Storage) PublishTask(ctx context.Context, id int) (Task, error) {
+	if err := s.checkWritable(); err != nil {
+		return Task{}, err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return err
+		return Task{}, err
 	}
+	defer tx.Rollback(ctx)
 
-	return nil
+	t, err := scanTask(tx.QueryRow(ctx, `
+		SELECT `+taskColumns+` FROM tasks t WHERE t.id = $1 FOR UPDATE;
+		`,
+		id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Task{}, ErrTaskNotFound
+	}
+	if err != nil {
+		return Task{}, err
+	}
+	if !t.Draft {
+		return t, nil
+	}
+	if err = t.validate(); err != nil {
+		return Task{}, err
+	}
+	t, err = scanTask(tx.QueryRow(ctx, `
+		UPDATE tasks AS t SET draft = false
+		WHERE t.id = $1
+		RETURNING `+taskColumns+`;
+		`,
+		id,
+	))
+	if err != nil {
+		return Task{}, err
+	}
+	if err = s.checkQuota(ctx, tx, t.AuthorID); err != nil {
+		return Task{}, err
+	}
+	if err = addEvent(ctx, tx, EventTaskUpdated, t); err != nil {
+		return Task{}, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return Task{}, err
+	}
+	return t, nil
 }
@@ -1,3 +1,330 @@
 package storage
 
 // тесты для проверки работы (тестирование будет изучаться в курсе позже).
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// testStorage подключается к тестовой БД, строка подключения к которой
+// задаётся переменной окружения TASKS_TEST_DB. БД должна быть создана
+// по схеме schema.sql. Если переменная не задана, тест пропускается.
+func testStorage(tb testing.TB) *Storage {
+	constr := os.Getenv("TASKS_TEST_DB")
+	if constr == "" {
+		tb.Skip("TASKS_TEST_DB не задана")
+	}
+	s, err := New(constr)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return s
+}
+
+// seedTasks дополняет таблицу задач до n строк.
+func seedTasks(tb testing.TB, s *Storage, n int) {
+	ctx := context.Background()
+	var count int
+	err := s.db.QueryRow(ctx, `SELECT count(*) FROM tasks;`).Scan(&count)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if count >= n {
+		return
+	}
+	rows := make([][]any, 0, n-count)
+	for i := count; i < n; i++ {
+		rows = append(rows, []any{"benchmark task", "benchmark content"})
+	}
+	_, err = s.db.CopyFrom(ctx, pgx.Identifier{"tasks"}, []string{"title", "content"}, pgx.CopyFromRows(rows))
+	if err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func BenchmarkTasks(b *testing.B) {
+	s := testStorage(b)
+	seedTasks(b, s, 100_000)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Tasks(ctx, 0, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTaskByAuthor(b *testing.B) {
+	s := testStorage(b)
+	seedTasks(b, s, 100_000)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.TaskByAuthor(ctx, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Фильтр по умолчанию применим к таблице task_summaries:
+// все столбцы условий фильтра должны в нём присутствовать.
+func TestTaskSummaries(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+	if _, err := s.TaskSummaries(ctx, TaskFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.TaskSummaries(ctx, TaskFilter{Drafts: true, Snoozed: true, Label: "bug"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Сводка обновляется триггерами вместе с задачей и её метками.
+func TestTaskSummariesFollowTasks(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+	label := fmt.Sprintf("summary-%d", time.Now().UnixNano())
+	summaries := func() []TaskSummary {
+		t.Helper()
+		ts, err := s.TaskSummaries(ctx, TaskFilter{Label: label})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	task, err := s.NewTaskWithLabels(ctx, Task{Title: "before"}, []string{label})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := summaries(); len(ts) != 1 || ts[0].Title != "before" || len(ts[0].Labels) != 1 || ts[0].Labels[0] != label {
+		t.Fatalf("after insert = %+v, want the new task with its label", ts)
+	}
+	task.Title = "after"
+	if _, err = s.UpdateTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	if ts := summaries(); len(ts) != 1 || ts[0].Title != "after" {
+		t.Fatalf("after update = %+v, want title %q", ts, "after")
+	}
+	if _, err = s.DeleteTask(ctx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ts := summaries(); len(ts) != 0 {
+		t.Fatalf("after delete = %+v, want none", ts)
+	}
+}
+
+func TestLabelStatsCascadeDelete(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+	label := fmt.Sprintf("cascade-%d", time.Now().UnixNano())
+	stat := func() LabelStat {
+		t.Helper()
+		stats, err := s.LabelStats(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, st := range stats {
+			if st.Name == label {
+				return st
+			}
+		}
+		return LabelStat{}
+	}
+	open, err := s.NewTaskWithLabels(ctx, Task{Title: "open"}, []string{label})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, err := s.NewTaskWithLabels(ctx, Task{Title: "closed", Closed: time.Now().Unix()}, []string{label})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := stat(); st.Open != 1 || st.Closed != 1 {
+		t.Fatalf("after insert = %+v, want 1 open, 1 closed", st)
+	}
+	// удаление в обход хранилища: связи удаляются каскадом
+	if _, err = s.db.Exec(ctx, `DELETE FROM tasks WHERE id = ANY($1);`, []int{open.ID, closed.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if st := stat(); st.Open != 0 || st.Closed != 0 {
+		t.Fatalf("after cascade delete = %+v, want zero counters", st)
+	}
+}
+
+// Событие транзакции, начавшейся раньше, идёт в ленте первым, даже если
+// его id больше, а события начавшихся позже транзакций не видны,
+// пока она не завершится.
+func TestEventsAfterOrder(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+	last, err := s.LastEventID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ids возвращает id событий ленты после last из числа want
+	ids := func(want ...int64) []int64 {
+		t.Helper()
+		events, err := s.EventsAfter(ctx, last, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int64
+		for _, e := range events {
+			for _, id := range want {
+				if e.ID == id {
+					got = append(got, e.ID)
+				}
+			}
+		}
+		return got
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err = tx.Exec(ctx, `SELECT pg_current_xact_id();`); err != nil {
+		t.Fatal(err)
+	}
+	later, err := s.NewTask(ctx, Task{Title: "later transaction"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var laterEvent int64
+	err = s.db.QueryRow(ctx, `SELECT max(id) FROM outbox WHERE task_id = $1;`, later.ID).Scan(&laterEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = addEvent(ctx, tx, EventTaskUpdated, Task{ID: later.ID, Title: "earlier transaction"}); err != nil {
+		t.Fatal(err)
+	}
+	var earlierEvent int64
+	if err = tx.QueryRow(ctx, `SELECT currval('outbox_id_seq');`).Scan(&earlierEvent); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(laterEvent, earlierEvent); len(got) != 0 {
+		t.Fatalf("events while an earlier transaction runs = %v, want none", got)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := ids(laterEvent, earlierEvent)
+	if len(got) != 2 || got[0] != earlierEvent || got[1] != laterEvent {
+		t.Fatalf("events = %v, want [%d %d]", got, earlierEvent, laterEvent)
+	}
+}
+
+// Событие изменения меток между двумя изменениями задачи не участвует
+// в сравнении состояний.
+func TestTaskDiffSkipsLabelEvents(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+	task, err := s.NewTask(ctx, Task{Title: "diff", Content: "one"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Title = "diff updated"
+	if task, err = s.UpdateTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.AddLabelToTasks(ctx, fmt.Sprintf("diff-%d", time.Now().UnixNano()), []int{task.ID}); err != nil {
+		t.Fatal(err)
+	}
+	task.Content = "two"
+	if _, err = s.UpdateTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := s.TaskEvents(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []string{EventTaskCreated, EventTaskUpdated, EventTaskLabels, EventTaskUpdated}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Fatalf("event types = %v, want %v", types, want)
+	}
+
+	changes, err := s.TaskDiff(ctx, task.ID, events[1].ID, events[3].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// время изменения может совпасть, если изменения пришлись на одну секунду
+	var fields []string
+	for _, c := range changes {
+		if c.Field != "updated" {
+			fields = append(fields, c.Field)
+		}
+	}
+	if fmt.Sprint(fields) != "[content]" {
+		t.Errorf("changed fields = %v, want [content]", changes)
+	}
+	if _, err = s.TaskDiff(ctx, task.ID, events[1].ID, events[2].ID); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("diff against a label event: err = %v, want ErrEventNotFound", err)
+	}
+}
+
+func TestIsItemError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{ErrInvalidTask, true},
+		{fmt.Errorf("task 3: %w", ErrQuotaExceeded), true},
+		{ErrDuplicateUID, true},
+		{&pgconn.PgError{Code: "23503"}, true},
+		{&pgconn.PgError{Code: "22003"}, true},
+		{&pgconn.PgError{Code: "57014"}, false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+		{errors.New("conn closed"), false},
+	}
+	for _, tt := range tests {
+		if got := isItemError(tt.err); got != tt.want {
+			t.Errorf("isItemError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	constr := os.Getenv("TASKS_TEST_DB")
+	if constr == "" {
+		t.Skip("TASKS_TEST_DB не задана")
+	}
+	s, err := New(constr, WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	show := func(ctx context.Context) string {
+		var v string
+		if err := s.db.QueryRow(ctx, `SHOW statement_timeout;`).Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	ctx := context.Background()
+	if got := show(ctx); got != "1s" {
+		t.Errorf("default statement_timeout = %q, want 1s", got)
+	}
+	if got := show(WithTimeout(ctx, time.Minute)); got != "1min" {
+		t.Errorf("WithTimeout statement_timeout = %q, want 1min", got)
+	}
+	if got := show(WithTimeout(ctx, 0)); got != "0" {
+		t.Errorf("disabled statement_timeout = %q, want 0", got)
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+)
+
+// Краткие сведения о задаче для списков:
+// задача вместе с именем автора и названиями меток.
+type TaskSummary struct {
+	ID         int
+	Opened     int64
+	Closed     int64
+	AuthorID   int
+	AuthorName string
+	AssignedID int
+	Title      string
+	Labels     []string
+}
+
+// TaskSummaries возвращает сведения о задачах из таблицы task_summaries.
+// Таблица обновляется триггерами БД в той же транзакции, что и задача,
+// так что сводка не отстаёт от задач и не требует полного пересчёта.
+func (s *Storage) TaskSummaries(ctx context.Context, f TaskFilter) ([]TaskSummary, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	where, args := f.where(nil)
+	rows, err := s.db.Query(ctx, `
+		SELECT
+			t.id,
+			t.opened,
+			t.closed,
+			t.author_id,
+			t.author_name,
+			t.assigned_id,
+			t.title,
+			t.labels
+		FROM task_summaries t
+		WHERE `+where+`
+		ORDER BY t.id`+f.limit()+`;
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var summaries []TaskSummary
+	for rows.Next() {
+		var ts TaskSummary
+		err = rows.Scan(
+			&ts.ID,
+			&ts.Opened,
+			&ts.Closed,
+			&ts.AuthorID,
+			&ts.AuthorName,
+			&ts.AssignedID,
+			&ts.Title,
+			&ts.Labels,
+		)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, ts)
+	}
+	return summaries, rows.Err()
+}
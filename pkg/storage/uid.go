@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrDuplicateUID возвращается при создании задачи с UID,
+// который уже есть у другой задачи, например при повторной
+// синхронизации задачи, созданной без связи с сервером.
+var ErrDuplicateUID = errors.New("task uid already exists")
+
+// WithUIDGenerator задаёт генератор UID задач, создаваемых без UID,
+// например NewUUID или NewULID. По умолчанию UID выдаёт сервер
+// по умолчанию столбца tasks.uid.
+func WithUIDGenerator(gen func() string) Option {
+	return func(c *config) {
+		c.newUID = gen
+	}
+}
+
+// NewUUID возвращает случайный UUID версии 4.
+func NewUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
+
+// алфавит Base32 Крокфорда для ULID
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID возвращает ULID: 48 бит времени в миллисекундах и 80 случайных
+// бит в 26 символах Base32 Крокфорда. ULID упорядочены по времени создания.
+func NewULID() string {
+	var b [16]byte
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+	copy(b[:6], ms[2:])
+	rand.Read(b[6:])
+	// 128 бит кодируются группами по 5 бит, начиная со старших 2 бит
+	var s [26]byte
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:])
+}
+
+// TaskByUID возвращает задачу по UID, в том числе черновик.
+// Если задачи с таким UID нет, возвращается ErrTaskNotFound.
+func (s *Storage) TaskByUID(ctx context.Context, uid string) (Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	t, err := scanTask(s.db.QueryRow(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE t.uid = $1;
+	`,
+		uid,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Task{}, ErrTaskNotFound
+	}
+	return t, err
+}
+
+// isUniqueViolation сообщает, нарушает ли err уникальный индекс index.
+func isUniqueViolation(err error, index string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == index
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNewUUID(t *testing.T) {
+	// версия 4, вариант RFC 4122
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		u := NewUUID()
+		if !re.MatchString(u) {
+			t.Fatalf("NewUUID() = %q: bad format", u)
+		}
+		if seen[u] {
+			t.Fatalf("NewUUID() = %q: duplicate", u)
+		}
+		seen[u] = true
+	}
+}
+
+func TestNewULID(t *testing.T) {
+	// первый символ кодирует старшие 3 бита 128-битного числа
+	re := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	seen := make(map[string]bool)
+	prev := NewULID()
+	for i := 0; i < 1000; i++ {
+		u := NewULID()
+		if !re.MatchString(u) {
+			t.Fatalf("NewULID() = %q: bad format", u)
+		}
+		if seen[u] {
+			t.Fatalf("NewULID() = %q: duplicate", u)
+		}
+		seen[u] = true
+		// порядок по времени гарантирован только между разными миллисекундами
+		if u[:10] < prev[:10] {
+			t.Fatalf("NewULID() = %q after %q: time went backwards", u, prev)
+		}
+		prev = u
+	}
+}
+
+func TestNewULIDTime(t *testing.T) {
+	before := time.Now().UnixMilli()
+	u := NewULID()
+	after := time.Now().UnixMilli()
+	// первые 10 символов - 48 бит времени в миллисекундах
+	var ms int64
+	for _, c := range u[:10] {
+		ms = ms<<5 | int64(indexCrockford(t, c))
+	}
+	if ms < before || ms > after {
+		t.Errorf("NewULID() = %q encodes %d, want within [%d, %d]", u, ms, before, after)
+	}
+}
+
+func indexCrockford(t *testing.T, c rune) int {
+	for i, d := range crockford {
+		if d == c {
+			return i
+		}
+	}
+	t.Fatalf("%q is not a Crockford Base32 digit", c)
+	return 0
+}
@@ -0,0 +1,99 @@
+package storage
+
+import "context"
+
+// Пользователь системы.
+type User struct {
+	ID    int
+	Name  string
+	Email string
+}
+
+// Задача вместе со сведениями об авторе, ответственном
+// и выполнении чек-листа.
+type TaskDetail struct {
+	Task
+	Author    User
+	Assignee  User
+	Checklist ChecklistProgress
+}
+
+// TaskDetails возвращает задачи, подходящие под фильтр, вместе с именами
+// и адресами электронной почты автора и ответственного, полученными
+// соединением с таблицей пользователей в том же запросе, и числом
+// пунктов чек-листа.
+func (s *Storage) TaskDetails(ctx context.Context, f TaskFilter) ([]TaskDetail, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	where, args := f.where(nil)
+	order, args := f.orderBy(args)
+	rows, err := s.db.Query(ctx, `
+		SELECT `+taskColumns+`,
+			coalesce(a.name, ''),
+			coalesce(a.email, ''),
+			coalesce(r.name, ''),
+			coalesce(r.email, ''),
+			cl.total,
+			cl.done
+		FROM tasks t
+		LEFT JOIN users a ON a.id = t.author_id
+		LEFT JOIN users r ON r.id = t.assigned_id
+		CROSS JOIN LATERAL (
+			SELECT count(*), count(*) FILTER (WHERE c.done)
+			FROM checklist_items c
+			WHERE c.task_id = t.id
+		) cl(total, done)
+		WHERE `+where+`
+		ORDER BY `+order+f.limit()+`;
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var details []TaskDetail
+	for rows.Next() {
+		var d TaskDetail
+		dest := append(d.Task.dest(),
+			&d.Author.Name,
+			&d.Author.Email,
+			&d.Assignee.Name,
+			&d.Assignee.Email,
+			&d.Checklist.Total,
+			&d.Checklist.Done,
+		)
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		d.Author.ID = d.AuthorID
+		d.Assignee.ID = d.AssignedID
+		details = append(details, d)
+	}
+	return details, rows.Err()
+}
+
+// Assignees возвращает id ответственных за открытые задачи по возрастанию.
+func (s *Storage) Assignees(ctx context.Context) ([]int, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT DISTINCT t.assigned_id
+		FROM tasks t
+		WHERE t.closed = 0 AND t.assigned_id <> 0 AND NOT t.draft
+		ORDER BY t.assigned_id;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}